@@ -0,0 +1,413 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenLifetime is the documented lifetime of a token returned by the issueToken endpoint.
+const tokenLifetime = time.Minute * 10
+
+// entraTokenExpiryMargin is how long before expiry a Microsoft Entra ID token is renewed.
+const entraTokenExpiryMargin = time.Minute * 5
+
+// TokenProvider supplies the credentials attached to every request sent to the Speech service.
+// AzureCS, AzureCSTTS and AzureCSSTT consult the provider for each HTTP and websocket request.
+//
+// Providers whose credentials expire may additionally implement `Refresh(ctx context.Context) error`;
+// AzureCS then fetches the initial credential at construction, refreshes it in the background and
+// refreshes it once more when a request is rejected with 401. Providers that also implement
+// `ExpiresOn() time.Time` are refreshed ahead of that expiry. Implementations must be safe for concurrent use.
+type TokenProvider interface {
+	// Authorize sets the authentication headers on an outgoing request.
+	Authorize(ctx context.Context, header http.Header) error
+}
+
+// tokenRefresher is implemented by providers that need periodic refreshing.
+type tokenRefresher interface {
+	Refresh(ctx context.Context) error
+}
+
+// tokenExpirer is implemented by providers that know when their current credential expires.
+type tokenExpirer interface {
+	ExpiresOn() time.Time
+}
+
+// tokenIssuer is implemented by providers that know when their current credential was issued.
+type tokenIssuer interface {
+	IssuedAt() time.Time
+}
+
+// keyUpdater is implemented by providers whose subscription key can be replaced on a live client.
+type keyUpdater interface {
+	UpdateSubscriptionKey(ctx context.Context, key string) error
+}
+
+// IssueTokenProvider exchanges a subscription key for a short-lived bearer token via the issueToken endpoint.
+// When a secondary key is set, it is tried whenever the issueToken endpoint rejects the primary key with
+// 401 or 403. It is safe for concurrent use.
+type IssueTokenProvider struct {
+	mu              sync.RWMutex
+	accessToken     string    // is the auth token received from `TokenRefreshAPI`. Used in the Authorization: Bearer header.
+	expiresOn       time.Time // expiry of accessToken, read from its `exp` claim.
+	issuedAt        time.Time // when accessToken was received.
+	refreshMu       sync.Mutex
+	subscriptionKey string // API key for Azure's Cognitive Speech services, guarded by mu.
+	secondaryKey    string // the resource's other key, tried when subscriptionKey is rejected; guarded by mu.
+	tokenRefreshURL string
+	refreshTimeout  time.Duration // bounds a single fetch; tokenRefreshTimeout when zero.
+	userAgent       string
+	retryPolicy     RetryPolicy
+	telemetry       *telemetry   // records a span for every fetch; nil when not built by NewWithClient.
+	logger          *slog.Logger // nil when not built by NewWithClient.
+	httpClient      *http.Client
+}
+
+// NewIssueTokenProvider returns a provider which exchanges `subscriptionKey` for a bearer token at `tokenRefreshURL`.
+func NewIssueTokenProvider(client *http.Client, subscriptionKey string, tokenRefreshURL string) *IssueTokenProvider {
+	return &IssueTokenProvider{
+		subscriptionKey: subscriptionKey,
+		tokenRefreshURL: tokenRefreshURL,
+		retryPolicy:     DefaultRetryPolicy(),
+		httpClient:      client,
+	}
+}
+
+// Authorize sets the Authorization: Bearer header. A token is fetched synchronously when none is cached
+// or the cached one has expired.
+func (p *IssueTokenProvider) Authorize(ctx context.Context, header http.Header) error {
+	token, expiresOn := p.token()
+	if token == "" || !time.Now().Before(expiresOn) {
+		p.refreshMu.Lock()
+		// another caller may have refreshed the token while we were waiting.
+		token, expiresOn = p.token()
+		if token == "" || !time.Now().Before(expiresOn) {
+			if err := p.fetchLocked(ctx); err != nil {
+				p.refreshMu.Unlock()
+				return err
+			}
+			token, _ = p.token()
+		}
+		p.refreshMu.Unlock()
+	}
+	header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// ExpiresOn returns the expiry of the cached token, or the zero time if no token has been fetched.
+func (p *IssueTokenProvider) ExpiresOn() time.Time {
+	_, expiresOn := p.token()
+	return expiresOn
+}
+
+// IssuedAt returns when the cached token was received, or the zero time if no token has been fetched.
+func (p *IssueTokenProvider) IssuedAt() time.Time {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.issuedAt
+}
+
+func (p *IssueTokenProvider) token() (string, time.Time) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.accessToken, p.expiresOn
+}
+
+func (p *IssueTokenProvider) keys() (primary, secondary string) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.subscriptionKey, p.secondaryKey
+}
+
+// SetSecondaryKey sets the key tried when the issueToken endpoint rejects the subscription key with 401
+// or 403, typically the resource's KEY2. An empty key disables the failover.
+func (p *IssueTokenProvider) SetSecondaryKey(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.secondaryKey = key
+}
+
+// UpdateSubscriptionKey exchanges `key` for a token and, once that succeeded, uses it in place of the
+// current subscription key. Requests keep using the current token while the exchange is in flight, and a
+// rejected key leaves the provider unchanged.
+func (p *IssueTokenProvider) UpdateSubscriptionKey(ctx context.Context, key string) (err error) {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	ctx, op := p.telemetry.start(ctx, "token_refresh")
+	defer func() { op.end(err) }()
+	token, err := p.issue(ctx, op, key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch a token with the new subscription key, %w", err)
+	}
+
+	p.mu.Lock()
+	p.subscriptionKey = key
+	p.mu.Unlock()
+	p.store(ctx, token)
+	p.log().InfoContext(ctx, "subscription key updated")
+	return nil
+}
+
+func (p *IssueTokenProvider) log() *slog.Logger {
+	if p.logger == nil {
+		return discardLogger
+	}
+	return p.logger
+}
+
+// Refresh fetches an updated token from the Azure cognitive speech/text services, or an error if unable to retrive.
+// Each token is valid for a maximum of 10 minutes. Details for auth tokens are referenced at
+// https://docs.microsoft.com/en-us/azure/cognitive-services/speech-service/rest-apis#authentication .
+// Note: This does not need to be called by a client, since this automatically runs via a background go-routine (`startRefresher`)
+func (p *IssueTokenProvider) Refresh(ctx context.Context) error {
+	// serialize fetches so that concurrent callers do not stampede the issueToken endpoint.
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+	return p.fetchLocked(ctx)
+}
+
+func (p *IssueTokenProvider) fetchLocked(ctx context.Context) (err error) {
+	ctx, op := p.telemetry.start(ctx, "token_refresh")
+	defer func() { op.end(err) }()
+
+	primary, secondary := p.keys()
+	token, err := p.issue(ctx, op, primary)
+	if err != nil && secondary != "" && isKeyRejected(err) {
+		p.log().WarnContext(ctx, "subscription key rejected, trying the secondary key", "error", err)
+		if token, err = p.issue(ctx, op, secondary); err == nil {
+			// the secondary key is tried first from now on, until the keys are updated.
+			p.mu.Lock()
+			p.subscriptionKey, p.secondaryKey = secondary, primary
+			p.mu.Unlock()
+		}
+	}
+	if err != nil {
+		return err
+	}
+	p.store(ctx, token)
+	return nil
+}
+
+// issue exchanges `key` for a token at the issueToken endpoint.
+func (p *IssueTokenProvider) issue(ctx context.Context, op *operation, key string) (string, error) {
+	timeout := p.refreshTimeout
+	if timeout <= 0 {
+		timeout = tokenRefreshTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenRefreshURL, nil)
+	request.Header.Set("Ocp-Apim-Subscription-Key", key)
+	if p.userAgent != "" {
+		request.Header.Set("User-Agent", p.userAgent)
+	}
+
+	logger := p.log()
+	res, err := p.retryPolicy.do(request, func(req *http.Request) (*http.Response, error) {
+		return logRoundTrip(ctx, logger, p.httpClient, req)
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	op.setResponse(res)
+
+	if res.StatusCode != http.StatusOK {
+		return "", newAPIError("token refresh", res, tokenStatusDescriptions)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response, %v", err)
+	}
+	return string(body), nil
+}
+
+// store caches a freshly issued token.
+func (p *IssueTokenProvider) store(ctx context.Context, token string) {
+	now := time.Now()
+	expiresOn := tokenExpiry(token, now)
+	p.mu.Lock()
+	p.accessToken = token
+	p.expiresOn = expiresOn
+	p.issuedAt = now
+	p.mu.Unlock()
+	p.log().DebugContext(ctx, "token issued", "expires_on", expiresOn)
+}
+
+// isKeyRejected reports whether the issueToken endpoint refused the subscription key itself, as opposed
+// to failing for a reason another key cannot fix.
+func isKeyRejected(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden)
+}
+
+// tokenExpiry returns the expiry recorded in the `exp` claim of a JWT. Tokens which cannot be decoded
+// are assumed to carry the documented 10 minute lifetime from `issuedAt`.
+func tokenExpiry(token string, issuedAt time.Time) time.Time {
+	fallback := issuedAt.Add(tokenLifetime)
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fallback
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return fallback
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return fallback
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+// StaticTokenProvider sends a pre-issued bearer token. The caller is responsible for its validity.
+type StaticTokenProvider struct {
+	token string
+}
+
+// NewStaticTokenProvider returns a provider which always sends `token` as the bearer token.
+func NewStaticTokenProvider(token string) *StaticTokenProvider {
+	return &StaticTokenProvider{token: token}
+}
+
+// Authorize sets the Authorization: Bearer header.
+func (p *StaticTokenProvider) Authorize(_ context.Context, header http.Header) error {
+	header.Set("Authorization", "Bearer "+p.token)
+	return nil
+}
+
+// SubscriptionKeyProvider sends the subscription key directly in the Ocp-Apim-Subscription-Key header
+// instead of exchanging it for a token.
+type SubscriptionKeyProvider struct {
+	mu              sync.RWMutex
+	subscriptionKey string
+}
+
+// NewSubscriptionKeyProvider returns a provider which authenticates every request with `subscriptionKey`.
+func NewSubscriptionKeyProvider(subscriptionKey string) *SubscriptionKeyProvider {
+	return &SubscriptionKeyProvider{subscriptionKey: subscriptionKey}
+}
+
+// Authorize sets the Ocp-Apim-Subscription-Key header.
+func (p *SubscriptionKeyProvider) Authorize(_ context.Context, header http.Header) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	header.Set("Ocp-Apim-Subscription-Key", p.subscriptionKey)
+	return nil
+}
+
+// UpdateSubscriptionKey sends `key` with every following request. The key is not validated.
+func (p *SubscriptionKeyProvider) UpdateSubscriptionKey(_ context.Context, key string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.subscriptionKey = key
+	return nil
+}
+
+// noAuthProvider sends no credentials. It is used for disconnected Speech containers.
+type noAuthProvider struct{}
+
+// Authorize leaves the request headers untouched.
+func (noAuthProvider) Authorize(context.Context, http.Header) error {
+	return nil
+}
+
+// EntraTokenSource returns a Microsoft Entra ID access token for `scope` along with its expiry.
+// It is typically a thin wrapper around an azidentity credential's GetToken.
+type EntraTokenSource func(ctx context.Context, scope string) (token string, expiresOn time.Time, err error)
+
+// EntraIDTokenProvider authenticates with Microsoft Entra ID access tokens.
+type EntraIDTokenProvider struct {
+	mu         sync.Mutex
+	source     EntraTokenSource
+	resourceID string
+	scope      string
+	token      string
+	expiresOn  time.Time
+	issuedAt   time.Time
+}
+
+// NewEntraIDTokenProvider returns a provider backed by `source`. `resourceID` is the Azure resource ID of the
+// Speech resource, e.g. /subscriptions/.../providers/Microsoft.CognitiveServices/accounts/<name>. It is required
+// for regional endpoints and may be left empty when the client targets a custom subdomain.
+func NewEntraIDTokenProvider(source EntraTokenSource, resourceID string) *EntraIDTokenProvider {
+	return NewEntraIDTokenProviderForCloud(CloudPublic, source, resourceID)
+}
+
+// NewEntraIDTokenProviderForCloud is NewEntraIDTokenProvider for a resource in `cloud`, which determines the
+// requested scope, e.g. https://cognitiveservices.azure.us/.default for CloudUSGov.
+func NewEntraIDTokenProviderForCloud(cloud Cloud, source EntraTokenSource, resourceID string) *EntraIDTokenProvider {
+	return &EntraIDTokenProvider{
+		source:     source,
+		resourceID: resourceID,
+		scope:      cloud.hosts().entraScope,
+	}
+}
+
+// Authorize sets the Authorization: Bearer header, fetching a new token from the source when the cached one
+// is missing or about to expire.
+func (p *EntraIDTokenProvider) Authorize(ctx context.Context, header http.Header) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token == "" || time.Until(p.expiresOn) < entraTokenExpiryMargin {
+		if err := p.refreshLocked(ctx); err != nil {
+			return err
+		}
+	}
+
+	if p.resourceID == "" {
+		header.Set("Authorization", "Bearer "+p.token)
+	} else {
+		header.Set("Authorization", "Bearer aad#"+p.resourceID+"#"+p.token)
+	}
+	return nil
+}
+
+// ExpiresOn returns the expiry of the cached token, or the zero time if no token has been fetched.
+func (p *EntraIDTokenProvider) ExpiresOn() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.expiresOn
+}
+
+// IssuedAt returns when the cached token was received from the source, or the zero time if no token has
+// been fetched.
+func (p *EntraIDTokenProvider) IssuedAt() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.issuedAt
+}
+
+// Refresh unconditionally fetches a new token from the source.
+func (p *EntraIDTokenProvider) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.refreshLocked(ctx)
+}
+
+func (p *EntraIDTokenProvider) refreshLocked(ctx context.Context) error {
+	token, expiresOn, err := p.source(ctx, p.scope)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Microsoft Entra ID token, %w", err)
+	}
+	p.token = token
+	p.expiresOn = expiresOn
+	p.issuedAt = time.Now()
+	return nil
+}
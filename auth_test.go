@@ -0,0 +1,235 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStaticAndSubscriptionKeyProviders(t *testing.T) {
+	header := http.Header{}
+	require.NoError(t, NewStaticTokenProvider("token").Authorize(context.Background(), header))
+	assert.Equal(t, "Bearer token", header.Get("Authorization"))
+
+	header = http.Header{}
+	require.NoError(t, NewSubscriptionKeyProvider("key").Authorize(context.Background(), header))
+	assert.Equal(t, "key", header.Get("Ocp-Apim-Subscription-Key"))
+	assert.Empty(t, header.Get("Authorization"))
+}
+
+func TestEntraIDTokenProvider(t *testing.T) {
+	calls := 0
+	source := func(ctx context.Context, scope string) (string, time.Time, error) {
+		calls++
+		assert.Equal(t, "https://cognitiveservices.azure.com/.default", scope)
+		return "entra", time.Now().Add(time.Hour), nil
+	}
+
+	p := NewEntraIDTokenProvider(source, "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.CognitiveServices/accounts/speech")
+	header := http.Header{}
+	require.NoError(t, p.Authorize(context.Background(), header))
+	require.NoError(t, p.Authorize(context.Background(), header))
+	assert.Equal(t, "Bearer aad#/subscriptions/sub/resourceGroups/rg/providers/Microsoft.CognitiveServices/accounts/speech#entra", header.Get("Authorization"))
+	assert.Equal(t, 1, calls, "cached token should be reused until it is about to expire")
+
+	p = NewEntraIDTokenProvider(source, "")
+	header = http.Header{}
+	require.NoError(t, p.Authorize(context.Background(), header))
+	assert.Equal(t, "Bearer entra", header.Get("Authorization"))
+}
+
+func TestNewWithTokenProviderSkipsRefreshForStaticProvider(t *testing.T) {
+	az, cleanup, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Nil(t, az.tokenRefreshDoneCh)
+}
+
+func TestTokenExpiry(t *testing.T) {
+	issuedAt := time.Unix(1700000000, 0)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700000600}`))
+	assert.Equal(t, time.Unix(1700000600, 0), tokenExpiry("header."+payload+".signature", issuedAt))
+	assert.Equal(t, issuedAt.Add(tokenLifetime), tokenExpiry("opaque", issuedAt))
+}
+
+func TestIssueTokenProviderConcurrentAuthorize(t *testing.T) {
+	var fetches int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Write([]byte("token"))
+	}))
+	defer ts.Close()
+
+	p := NewIssueTokenProvider(http.DefaultClient, "key", ts.URL)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			header := http.Header{}
+			assert.NoError(t, p.Authorize(context.Background(), header))
+			assert.Equal(t, "Bearer token", header.Get("Authorization"))
+		}()
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, p.Refresh(context.Background()))
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, atomic.LoadInt32(&fetches), int32(17))
+}
+
+func TestDoRefreshesTokenOnUnauthorized(t *testing.T) {
+	tokens := []string{"stale", "fresh"}
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tokens[atomic.AddInt32(&issued, 1)-1]))
+	}))
+	defer tokenServer.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	az := &AzureCS{
+		tokenProvider: NewIssueTokenProvider(http.DefaultClient, "key", tokenServer.URL),
+		httpClient:    http.DefaultClient,
+	}
+	tts := &AzureCSTTS{textToSpeechURL: ts.URL, client: az}
+	b, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "<speak/>", string(b))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued))
+}
+
+func TestUnauthorizedStreamedRequestRefreshesToken(t *testing.T) {
+	tokens := []string{"stale", "fresh"}
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tokens[atomic.AddInt32(&issued, 1)-1]))
+	}))
+	defer tokenServer.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"RecognitionStatus":"Success","DisplayText":"hello"}`))
+	}))
+	defer ts.Close()
+
+	stt := &AzureCSSTT{
+		speechToTextAPI: ts.URL,
+		client: &AzureCS{
+			tokenProvider: NewIssueTokenProvider(http.DefaultClient, "key", tokenServer.URL),
+			httpClient:    http.DefaultClient,
+		},
+	}
+	// io.MultiReader hides the underlying seeker, so the body cannot be replayed.
+	_, err := stt.RecognizeShortSimple(io.MultiReader(strings.NewReader("audio")), RIFF16khz16bitMonoPCM, "en-US")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&issued), "the rejected token must be replaced")
+
+	resp, err := stt.RecognizeShortSimple(io.MultiReader(strings.NewReader("audio")), RIFF16khz16bitMonoPCM, "en-US")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.DisplayText)
+}
+
+func TestTokenRefreshBackoff(t *testing.T) {
+	for failures := 1; failures < 40; failures++ {
+		backoff := tokenRefreshBackoff(failures)
+		assert.GreaterOrEqual(t, int64(backoff), int64(tokenRefreshMinBackoff/2))
+		assert.LessOrEqual(t, int64(backoff), int64(tokenRefreshMaxBackoff))
+	}
+}
+
+// newKeyCheckingTokenServer issues "token-<key>" for the keys in `valid` and rejects any other key with 401.
+func newKeyCheckingTokenServer(t *testing.T, valid ...string) (*httptest.Server, *int32) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		key := r.Header.Get("Ocp-Apim-Subscription-Key")
+		for _, v := range valid {
+			if key == v {
+				w.Write([]byte("token-" + key))
+				return
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestIssueTokenProviderFailsOverToSecondaryKey(t *testing.T) {
+	ts, calls := newKeyCheckingTokenServer(t, "key2")
+	provider := NewIssueTokenProvider(http.DefaultClient, "key1", ts.URL)
+	provider.retryPolicy = NoRetryPolicy()
+	provider.SetSecondaryKey("key2")
+
+	header := http.Header{}
+	require.NoError(t, provider.Authorize(context.Background(), header))
+	assert.Equal(t, "Bearer token-key2", header.Get("Authorization"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+
+	// the working key is tried first from now on.
+	require.NoError(t, provider.Refresh(context.Background()))
+	assert.Equal(t, int32(3), atomic.LoadInt32(calls))
+	primary, secondary := provider.keys()
+	assert.Equal(t, "key2", primary)
+	assert.Equal(t, "key1", secondary)
+}
+
+func TestIssueTokenProviderWithoutSecondaryKeyFails(t *testing.T) {
+	ts, _ := newKeyCheckingTokenServer(t, "key2")
+	provider := NewIssueTokenProvider(http.DefaultClient, "key1", ts.URL)
+	provider.retryPolicy = NoRetryPolicy()
+
+	err := provider.Refresh(context.Background())
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestUpdateSubscriptionKey(t *testing.T) {
+	ts, _ := newKeyCheckingTokenServer(t, "key1", "key2")
+	az, cleanup, err := NewWithClient(http.DefaultClient, "key1", RegionWestUS2,
+		WithTokenRefreshAPI(ts.URL), WithRetryPolicy(NoRetryPolicy()))
+	require.NoError(t, err)
+	defer cleanup()
+
+	header := http.Header{}
+	require.NoError(t, az.UpdateSubscriptionKey(context.Background(), "key2"))
+	require.NoError(t, az.authorize(context.Background(), header))
+	assert.Equal(t, "Bearer token-key2", header.Get("Authorization"))
+
+	// a rejected key leaves the working key and token in place.
+	require.Error(t, az.UpdateSubscriptionKey(context.Background(), "revoked"))
+	require.NoError(t, az.authorize(context.Background(), header))
+	assert.Equal(t, "Bearer token-key2", header.Get("Authorization"))
+
+	static, cleanup, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Error(t, static.UpdateSubscriptionKey(context.Background(), "key"))
+}
@@ -3,127 +3,327 @@ package azure_cs_sdk
 import (
 	"context"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"time"
 )
 
-// The following are V1 endpoints for Cognitive Services endpoints
-const textToSpeechAPI = "https://%s.tts.speech.microsoft.com/cognitiveservices"
-const speechToTextAPI = "https://%s.stt.speech.microsoft.com/speech/recognition/conversation/cognitiveservices/v1"
-const speechToTextWSAPI = "wss://%s.stt.speech.microsoft.com/stt/speech/universal/v2"
-const tokenRefreshAPI = "https://%s.api.cognitive.microsoft.com/sts/v1.0/issueToken"
+// The following are V1 endpoints for Cognitive Services endpoints, formatted with the region and the
+// host suffix of its Cloud (e.g. speech.microsoft.com and api.cognitive.microsoft.com).
+const textToSpeechAPI = "https://%s.tts.%s/cognitiveservices"
+const textToSpeechWSAPI = "wss://%s.tts.%s/cognitiveservices/websocket/v1"
+const speechToTextAPI = "https://%s.stt.%s/speech/recognition/conversation/cognitiveservices/v1"
+const speechToTextWSAPI = "wss://%s.stt.%s/stt/speech/universal/v2"
+const tokenRefreshAPI = "https://%s.%s/sts/v1.0/issueToken"
+const batchSynthesisAPI = "https://%s.%s/texttospeech/batchsyntheses"
 
-// tokenRefreshTimeout is the amount of time the http client will wait during the token refresh action.
+// tokenRefreshTimeout is the default amount of time the http client will wait during the token refresh action.
 const tokenRefreshTimeout = time.Second * 10
 
-// tokenRefreshInterval is the amount of time between token refreshes
+// tokenRefreshInterval is the default amount of time between token refreshes
 // ref: https://learn.microsoft.com/en-us/azure/ai-services/speech-service/rest-text-to-speech?tabs=streaming#how-to-use-an-access-token
 const tokenRefreshInterval = time.Minute * 9
 
+// tokenRefreshMargin is how long before the token expires the background refresher renews it.
+const tokenRefreshMargin = time.Minute
+
+// tokenRefreshMinBackoff and tokenRefreshMaxBackoff bound the delay between failed token refreshes.
+const tokenRefreshMinBackoff = time.Second
+const tokenRefreshMaxBackoff = time.Minute
+
 // AzureCS is the main object for the Azure Cognitive Services client.
 type AzureCS struct {
-	accessToken        string    // is the auth token received from `TokenRefreshAPI`. Used in the Authorization: Bearer header.
-	subscriptionKey    string    // API key for Azure's Cognitive Speech services
-	tokenRefreshDoneCh chan bool // channel to stop the token refresh goroutine.
-	tokenRefreshURL    string
-	region             Region
+	tokenProvider      TokenProvider // supplies the credentials attached to every request.
+	tokenRefreshDoneCh chan bool     // channel to stop the token refresh goroutine.
 	httpClient         *http.Client
+	opts               clientOptions
+	limits             *limits    // client-side rate limiter and recognition semaphore.
+	lifecycle          *lifecycle // tracks the calls in flight for Shutdown and Close.
+	health             *healthState
 }
 
-// New returns an AzureCS object.
-func New(subscriptionKey string, region Region) (*AzureCS, func(), error) {
-	return NewWithClient(http.DefaultClient, subscriptionKey, region)
+// New returns an AzureCS object. The returned function stops the token refresher and rejects new calls
+// with ErrClientClosed, but leaves the calls in flight running; use Shutdown or Close to wait for or
+// cancel them.
+func New(subscriptionKey string, region Region, opts ...ClientOption) (*AzureCS, func(), error) {
+	return NewWithClient(http.DefaultClient, subscriptionKey, region, opts...)
 }
 
 // NewWithClient returns an AzureCS object with a custom http client.
-func NewWithClient(client *http.Client, subscriptionKey string, region Region) (*AzureCS, func(), error) {
+func NewWithClient(client *http.Client, subscriptionKey string, region Region, opts ...ClientOption) (*AzureCS, func(), error) {
+	o, err := newClientOptions(region, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := NewIssueTokenProvider(client, subscriptionKey, o.tokenRefreshAPI)
+	provider.refreshTimeout = o.tokenRefreshTimeout
+	provider.userAgent = o.userAgent
+	provider.retryPolicy = o.retryPolicy
+	provider.telemetry = o.telemetry
+	provider.logger = o.logger
+	provider.secondaryKey = o.secondaryKey
+	return NewWithTokenProvider(client, provider, region, opts...)
+}
+
+// NewWithEndpoint returns an AzureCS object for a resource addressed by its endpoint URL, such as
+// https://<name>.cognitiveservices.azure.com, instead of a Region. Use this for resources with a
+// custom subdomain or behind a private endpoint.
+func NewWithEndpoint(client *http.Client, subscriptionKey string, endpoint string, opts ...ClientOption) (*AzureCS, func(), error) {
+	opts = append([]ClientOption{WithEndpointResolver(CustomDomainEndpoints(endpoint))}, opts...)
+	return NewWithClient(client, subscriptionKey, noRegion, opts...)
+}
+
+// NewForContainer returns an AzureCS object for an on-premises Speech container listening on `host`,
+// e.g. http://localhost:5000. Containers are reached over plain http and ws when `host` uses http,
+// and no token is fetched or sent.
+func NewForContainer(client *http.Client, host string, opts ...ClientOption) (*AzureCS, func(), error) {
+	opts = append([]ClientOption{WithEndpointResolver(ContainerEndpoints(host))}, opts...)
+	return NewWithTokenProvider(client, noAuthProvider{}, noRegion, opts...)
+}
+
+// NewWithTokenProvider returns an AzureCS object which authenticates every request through `provider`.
+func NewWithTokenProvider(client *http.Client, provider TokenProvider, region Region, opts ...ClientOption) (*AzureCS, func(), error) {
+	o, err := newClientOptions(region, opts)
+	if err != nil {
+		return nil, nil, err
+	}
 	az := &AzureCS{
-		subscriptionKey: subscriptionKey,
-		region:          region,
-		httpClient:      client,
+		tokenProvider: provider,
+		httpClient:    client,
+		opts:          o,
+		limits:        newLimits(o),
+		lifecycle:     newLifecycle(),
+		health:        &healthState{},
 	}
 
-	az.tokenRefreshURL = fmt.Sprintf(tokenRefreshAPI, region)
+	if refresher, ok := provider.(tokenRefresher); ok {
+		// api requires that the token is refreshed every 10 mintutes.
+		// We will do this task in the background every ~9 minutes.
+		// In lazy mode the first request fetches the token instead.
+		if !az.opts.lazy {
+			if err := az.refresh(context.Background(), refresher); err != nil {
+				return nil, nil, fmt.Errorf("failed to fetch initial token, %w", err)
+			}
+		}
+
+		az.tokenRefreshDoneCh = az.startRefresher(refresher)
+	}
+	return az, az.stop, nil
+}
+
+// Warmup fetches the initial token ahead of the first request. It is only useful for clients created
+// with WithLazyInit, whose construction does not touch the network.
+func (az *AzureCS) Warmup(ctx context.Context) error {
+	ctx, end, err := az.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return az.warmup(ctx)
+}
 
-	// api requires that the token is refreshed every 10 mintutes.
-	// We will do this task in the background every ~9 minutes.
-	if err := az.refreshToken(); err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch initial token, %v", err)
+func (az *AzureCS) warmup(ctx context.Context) error {
+	if refresher, ok := az.tokenProvider.(tokenRefresher); ok {
+		if err := az.refresh(ctx, refresher); err != nil {
+			return fmt.Errorf("failed to fetch initial token, %w", err)
+		}
+		return nil
 	}
+	return az.authorize(ctx, http.Header{})
+}
 
-	az.tokenRefreshDoneCh = az.startRefresher()
-	cleanup := func() {
-		close(az.tokenRefreshDoneCh)
+// UpdateSubscriptionKey swaps the subscription key of a live client, e.g. when a secrets watcher sees the
+// key rotated. For clients built from a subscription key, the new key is first exchanged for a token; if
+// the service rejects it, the error is returned and the client keeps its current key and token. Voice maps
+// and open sessions are unaffected. Clients built with other TokenProviders return an error.
+func (az *AzureCS) UpdateSubscriptionKey(ctx context.Context, key string) error {
+	updater, ok := az.tokenProvider.(keyUpdater)
+	if !ok {
+		return fmt.Errorf("the client's %T does not use a subscription key", az.tokenProvider)
 	}
-	return az, cleanup, nil
+	return updater.UpdateSubscriptionKey(ctx, key)
+}
+
+// LimitStats reports the time calls have spent waiting on the limits set by WithRateLimit and
+// WithMaxConcurrentRecognitions.
+func (az *AzureCS) LimitStats() LimitStats {
+	return az.limits.stats()
 }
 
-// NewTTS returns a new TTS client for the AzureCS object. This is used to create a new TTS client
+// NewTTS returns a new TTS client for the AzureCS object. This is used to create a new TTS client.
+// Unless the AzureCS object was created with WithLazyInit, the voice list is downloaded immediately.
 func (az *AzureCS) NewTTS() (*AzureCSTTS, error) {
-	base := fmt.Sprintf(textToSpeechAPI, az.region)
 	tts := &AzureCSTTS{
-		textToSpeechURL:     base + "/v1",
-		voiceServiceListURL: base + "/voices/list",
+		textToSpeechURL:     az.opts.synthesisURL(),
+		voiceServiceListURL: az.opts.voiceListURL(),
+		textToSpeechWSURL:   az.opts.textToSpeechWSAPI,
 		client:              az,
 	}
-	m, err := tts.buildVoiceToRegionMap()
-	if err != nil {
-		return nil, fmt.Errorf("failed to build voice to region map, %v", err)
+	if az.opts.lazy {
+		return tts, nil
+	}
+	if _, err := tts.voices(context.Background()); err != nil {
+		return nil, err
 	}
-	tts.regionVoiceMap = m
 	return tts, nil
 }
 
 func (az *AzureCS) NewSTT() (*AzureCSSTT, error) {
 	return &AzureCSSTT{
-		speechToTextAPI:   fmt.Sprintf(speechToTextAPI, az.region),
-		speechToTextWSAPI: fmt.Sprintf(speechToTextWSAPI, az.region),
+		speechToTextAPI:   az.opts.speechToTextAPI,
+		speechToTextWSAPI: az.opts.speechToTextWSAPI,
 		client:            az,
 	}, nil
 }
 
-// refreshToken fetches an updated token from the Azure cognitive speech/text services, or an error if unable to retrive.
-// Each token is valid for a maximum of 10 minutes. Details for auth tokens are referenced at
-// https://docs.microsoft.com/en-us/azure/cognitive-services/speech-service/rest-apis#authentication .
-// Note: This does not need to be called by a client, since this automatically runs via a background go-routine (`startRefresher`)
-func (az *AzureCS) refreshToken() error {
-	ctx, cancel := context.WithTimeout(context.Background(), tokenRefreshTimeout)
-	defer cancel()
+// authorize sets the authentication headers for a request through the configured TokenProvider.
+func (az *AzureCS) authorize(ctx context.Context, header http.Header) error {
+	err := az.tokenProvider.Authorize(ctx, header)
+	az.health.authorized(az.tokenProvider, err)
+	if err != nil {
+		return fmt.Errorf("failed to authorize request, %w", err)
+	}
+	return nil
+}
+
+// reauthorize refreshes the provider's credential after the service rejected it. It reports false when
+// the provider cannot be refreshed, in which case the rejection is final.
+func (az *AzureCS) reauthorize(ctx context.Context) (bool, error) {
+	refresher, ok := az.tokenProvider.(tokenRefresher)
+	if !ok {
+		return false, nil
+	}
+	if err := az.refresh(ctx, refresher); err != nil {
+		return true, fmt.Errorf("failed to refresh token after 401, %w", err)
+	}
+	return true, nil
+}
+
+// do authorizes and sends `req`, retrying transient failures according to the configured RetryPolicy.
+func (az *AzureCS) do(req *http.Request) (*http.Response, error) {
+	if az.opts.userAgent != "" {
+		req.Header.Set("User-Agent", az.opts.userAgent)
+	}
+	return az.opts.retryPolicy.do(req, az.send)
+}
 
-	request, _ := http.NewRequestWithContext(ctx, http.MethodPost, az.tokenRefreshURL, nil)
-	request.Header.Set("Ocp-Apim-Subscription-Key", az.subscriptionKey)
+// send authorizes and sends a single attempt of `req`, waiting for the client-side rate limiter first.
+func (az *AzureCS) send(req *http.Request) (*http.Response, error) {
+	if err := az.limits.waitRequest(req.Context()); err != nil {
+		return nil, err
+	}
+	return az.sendAuthorized(req)
+}
 
-	res, err := az.httpClient.Do(request)
+// sendAuthorized authorizes and sends a single attempt of `req`, bypassing the rate limiter. When the
+// service rejects the credential with 401, the token is refreshed synchronously so that later requests do
+// not reuse it, and the request is sent once more, provided its body can be replayed.
+func (az *AzureCS) sendAuthorized(req *http.Request) (*http.Response, error) {
+	if err := az.authorize(req.Context(), req.Header); err != nil {
+		return nil, err
+	}
+	res, err := az.roundTrip(req)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		return res, nil
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code; received http status=%s", res.Status)
+	refreshed, err := az.reauthorize(req.Context())
+	if !refreshed {
+		return res, nil
+	}
+	if !isReplayable(req) {
+		// the streamed body is gone; the 401 is returned, but the next request uses the new token.
+		if err != nil {
+			az.logger().Warn("failed to refresh token after 401", "error", err)
+		}
+		return res, nil
+	}
+	az.logger().Debug("credential rejected, retrying with a refreshed token", "url", redactURL(req.URL))
+	res.Body.Close()
+	if err != nil {
+		return nil, err
 	}
 
-	body, _ := io.ReadAll(res.Body)
-	az.accessToken = string(body)
-	return nil
+	retry, err := rewindRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := az.limits.waitRequest(retry.Context()); err != nil {
+		return nil, err
+	}
+	if err := az.authorize(retry.Context(), retry.Header); err != nil {
+		return nil, err
+	}
+	return az.roundTrip(retry)
+}
+
+// roundTrip sends `req` through the http client, logging a summary of the request and its response.
+func (az *AzureCS) roundTrip(req *http.Request) (*http.Response, error) {
+	return logRoundTrip(req.Context(), az.logger(), az.httpClient, req)
+}
+
+// logRoundTrip sends `req` through `client`, logging a redacted summary of the request and its response
+// at debug level.
+func logRoundTrip(ctx context.Context, logger *slog.Logger, client *http.Client, req *http.Request) (*http.Response, error) {
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return client.Do(req)
+	}
+
+	logger.DebugContext(ctx, "http request",
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"header", redactedHeader(req.Header))
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		logger.DebugContext(ctx, "http request failed",
+			"method", req.Method,
+			"url", redactURL(req.URL),
+			"duration", time.Since(start),
+			"error", err)
+		return nil, err
+	}
+
+	attrs := []any{
+		"method", req.Method,
+		"url", redactURL(req.URL),
+		"status", res.StatusCode,
+		"duration", time.Since(start),
+	}
+	if id := responseRequestID(res.Header); id != "" {
+		attrs = append(attrs, "request_id", id)
+	}
+	logger.DebugContext(ctx, "http response", attrs...)
+	return res, nil
 }
 
-// startRefresher updates the authentication token on at a 9 minute interval. A channel is returned
-// if the caller wishes to cancel the channel.
-func (az *AzureCS) startRefresher() chan bool {
+// startRefresher renews the authentication token shortly before it expires, or at the configured interval
+// (9 minutes by default) when the provider does not report an expiry. Failed refreshes are retried with jittered exponential backoff.
+// A channel is returned if the caller wishes to cancel the channel.
+func (az *AzureCS) startRefresher(refresher tokenRefresher) chan bool {
 	done := make(chan bool, 1)
 	go func() {
-		ticker := time.NewTicker(tokenRefreshInterval)
-		defer ticker.Stop()
+		timer := time.NewTimer(nextTokenRefresh(refresher, az.opts.tokenRefreshInterval))
+		defer timer.Stop()
+		failures := 0
 		for {
 			select {
-			case <-ticker.C:
-				err := az.refreshToken()
-				if err != nil {
-					log.Printf("failed to refresh token, %v", err)
+			case <-timer.C:
+				var wait time.Duration
+				if err := az.refresh(context.Background(), refresher); err != nil {
+					failures++
+					wait = tokenRefreshBackoff(failures)
+					az.logger().Warn("failed to refresh token", "failures", failures, "retry_in", wait, "error", err)
+				} else {
+					failures = 0
+					wait = nextTokenRefresh(refresher, az.opts.tokenRefreshInterval)
+					az.logger().Debug("token refreshed", "next_refresh_in", wait)
 				}
+				timer.Reset(wait)
 			case <-done:
 				return
 			}
@@ -131,3 +331,27 @@ func (az *AzureCS) startRefresher() chan bool {
 	}()
 	return done
 }
+
+// nextTokenRefresh returns how long to wait before the next scheduled refresh.
+func nextTokenRefresh(refresher tokenRefresher, interval time.Duration) time.Duration {
+	wait := interval
+	if expirer, ok := refresher.(tokenExpirer); ok {
+		if expiresOn := expirer.ExpiresOn(); !expiresOn.IsZero() {
+			wait = time.Until(expiresOn) - tokenRefreshMargin
+		}
+	}
+	if wait < tokenRefreshMinBackoff {
+		wait = tokenRefreshMinBackoff
+	}
+	return wait
+}
+
+// tokenRefreshBackoff returns the delay before retrying after `failures` consecutive failed refreshes.
+// The delay doubles with each failure up to tokenRefreshMaxBackoff, and half of it is randomized.
+func tokenRefreshBackoff(failures int) time.Duration {
+	backoff := tokenRefreshMaxBackoff
+	if failures < 16 {
+		backoff = min(tokenRefreshMinBackoff<<(failures-1), tokenRefreshMaxBackoff)
+	}
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
@@ -0,0 +1,482 @@
+package azure_cs_sdk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"time"
+)
+
+// batchSynthesisAPIVersion is the version of the batch synthesis REST API.
+// ref: https://learn.microsoft.com/en-us/azure/ai-services/speech-service/batch-synthesis
+const batchSynthesisAPIVersion = "2024-04-01"
+
+// batchPollInterval and batchPollMaxInterval are the default bounds of the delay between polls of a job.
+const batchPollInterval = time.Second * 5
+const batchPollMaxInterval = time.Minute
+
+// AzureCSBatchSynthesis is a client for the asynchronous batch synthesis API, which renders long
+// documents such as audiobooks as jobs running on the service rather than in real time.
+type AzureCSBatchSynthesis struct {
+	endpoint string
+	client   *AzureCS
+}
+
+// NewBatchSynthesis returns a new batch synthesis client for the AzureCS object. Containers do not
+// run batch synthesis, so an error is returned for clients without a batch synthesis endpoint.
+func (az *AzureCS) NewBatchSynthesis() (*AzureCSBatchSynthesis, error) {
+	if az.opts.batchSynthesisAPI == "" {
+		return nil, fmt.Errorf("batch synthesis is not available for this endpoint, set it with WithBatchSynthesisAPI")
+	}
+	return &AzureCSBatchSynthesis{endpoint: az.opts.batchSynthesisAPI, client: az}, nil
+}
+
+// BatchInput is one document of a batch synthesis job, rendered to its own audio file. The inputs of
+// a job are either all plain text or all SSML.
+type BatchInput struct {
+	text    string
+	ssml    xml.Token
+	rawSsml string
+	isSsml  bool
+}
+
+// BatchText returns an input speaking `text` with the voice of the job.
+func BatchText(text string) BatchInput {
+	return BatchInput{text: text}
+}
+
+// BatchSsml returns an input speaking `elems`, which are wrapped in a <speak> element.
+func BatchSsml(elems xml.Token) BatchInput {
+	return BatchInput{ssml: elems, isSsml: true}
+}
+
+// BatchRawSsml returns an input speaking the SSML document `ssml`.
+func BatchRawSsml(ssml string) BatchInput {
+	return BatchInput{rawSsml: ssml, isSsml: true}
+}
+
+// content returns the text or SSML document sent for the input.
+func (in BatchInput) content() (string, error) {
+	switch {
+	case !in.isSsml:
+		return in.text, nil
+	case in.ssml != nil:
+		return speakDocument(in.ssml)
+	}
+	return in.rawSsml, nil
+}
+
+// BatchSynthesisRequest describes a batch synthesis job.
+type BatchSynthesisRequest struct {
+	// Description is a free-form note shown when listing jobs.
+	Description string
+	// Inputs are the documents to synthesize, each to its own audio file.
+	Inputs []BatchInput
+	// Voice speaks plain text inputs, the voice set by WithDefaultVoice when empty. SSML inputs name
+	// their voices themselves.
+	Voice string
+	// WordBoundaryEnabled adds a JSON file with the timed words of each audio file.
+	WordBoundaryEnabled bool
+	// SentenceBoundaryEnabled adds a JSON file with the timed sentences of each audio file, from which
+	// subtitles can be generated.
+	SentenceBoundaryEnabled bool
+	// ConcatenateResult joins the audio of all inputs into a single file.
+	ConcatenateResult bool
+	// DestinationContainerURL is a writable SAS URL of an Azure Blob Storage container receiving the
+	// results, instead of the service's own storage. DownloadResults does not apply to such jobs.
+	DestinationContainerURL string
+	// DestinationPath is the folder of the results within DestinationContainerURL.
+	DestinationPath string
+	// DecompressOutputFiles writes the results to DestinationContainerURL as separate files rather than
+	// a single zip file.
+	DecompressOutputFiles bool
+	// TimeToLive is how long the job is kept after it finished, rounded up to whole hours. The service
+	// keeps jobs for 31 days when zero.
+	TimeToLive time.Duration
+}
+
+// BatchSynthesisStatus is the state of a batch synthesis job.
+type BatchSynthesisStatus string
+
+const (
+	BatchSynthesisNotStarted BatchSynthesisStatus = "NotStarted"
+	BatchSynthesisRunning    BatchSynthesisStatus = "Running"
+	BatchSynthesisSucceeded  BatchSynthesisStatus = "Succeeded"
+	BatchSynthesisFailed     BatchSynthesisStatus = "Failed"
+)
+
+// Done reports whether the job has finished, successfully or not.
+func (s BatchSynthesisStatus) Done() bool {
+	return s == BatchSynthesisSucceeded || s == BatchSynthesisFailed
+}
+
+// BatchSynthesisJob is a batch synthesis job as reported by the service.
+type BatchSynthesisJob struct {
+	ID                 string                   `json:"id"`
+	Description        string                   `json:"description,omitempty"`
+	Status             BatchSynthesisStatus     `json:"status"`
+	CreatedDateTime    time.Time                `json:"createdDateTime"`
+	LastActionDateTime time.Time                `json:"lastActionDateTime"`
+	Properties         BatchSynthesisProperties `json:"properties"`
+	Outputs            BatchSynthesisOutputs    `json:"outputs"`
+}
+
+// BatchSynthesisProperties are the results of a batch synthesis job, filled in once it has finished.
+type BatchSynthesisProperties struct {
+	TimeToLiveInHours      int                  `json:"timeToLiveInHours"`
+	DurationInMilliseconds int64                `json:"durationInMilliseconds"`
+	SizeInBytes            int64                `json:"sizeInBytes"`
+	SucceededAudioCount    int                  `json:"succeededAudioCount"`
+	FailedAudioCount       int                  `json:"failedAudioCount"`
+	Error                  *BatchSynthesisError `json:"error,omitempty"`
+}
+
+// BatchSynthesisOutputs locates the results of a batch synthesis job.
+type BatchSynthesisOutputs struct {
+	// Result is the URL of the zip file of the results, or of DestinationContainerURL.
+	Result string `json:"result"`
+}
+
+// BatchSynthesisError is the reason a batch synthesis job failed.
+type BatchSynthesisError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *BatchSynthesisError) Error() string {
+	if e.Code == "" {
+		return e.Message
+	}
+	return e.Code + ": " + e.Message
+}
+
+// BatchResultFile is a file of the results of a batch synthesis job, e.g. 0001.wav, 0001.word.json or
+// summary.json.
+type BatchResultFile struct {
+	Name string
+	Data []byte
+}
+
+// batchSynthesisDefinition is the body of a job creation request.
+type batchSynthesisDefinition struct {
+	Description     string                       `json:"description,omitempty"`
+	InputKind       string                       `json:"inputKind"`
+	SynthesisConfig *batchSynthesisConfig        `json:"synthesisConfig,omitempty"`
+	Inputs          []batchSynthesisInput        `json:"inputs"`
+	Properties      batchSynthesisDefinitionOpts `json:"properties"`
+}
+
+type batchSynthesisConfig struct {
+	Voice string `json:"voice"`
+}
+
+type batchSynthesisInput struct {
+	Content string `json:"content"`
+}
+
+type batchSynthesisDefinitionOpts struct {
+	OutputFormat            string `json:"outputFormat"`
+	WordBoundaryEnabled     bool   `json:"wordBoundaryEnabled,omitempty"`
+	SentenceBoundaryEnabled bool   `json:"sentenceBoundaryEnabled,omitempty"`
+	ConcatenateResult       bool   `json:"concatenateResult,omitempty"`
+	DecompressOutputFiles   bool   `json:"decompressOutputFiles,omitempty"`
+	DestinationContainerURL string `json:"destinationContainerUrl,omitempty"`
+	DestinationPath         string `json:"destinationPath,omitempty"`
+	TimeToLiveInHours       int    `json:"timeToLiveInHours,omitempty"`
+}
+
+// Create submits the job `id`, which synthesizes the inputs of `req` to files of the `audioOutput`
+// format. IDs are chosen by the caller and must be unique within the resource; use Wait to follow the job.
+func (az *AzureCSBatchSynthesis) Create(ctx context.Context, id string, audioOutput AudioType, req BatchSynthesisRequest) (*BatchSynthesisJob, error) {
+	if id == "" {
+		return nil, fmt.Errorf("no batch synthesis job ID given")
+	}
+	definition, err := az.definition(audioOutput, req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	var job BatchSynthesisJob
+	if err := az.call(ctx, "create", http.MethodPut, az.jobURL(id), body, &job, http.StatusOK, http.StatusCreated); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// definition returns the body creating a job for `req`.
+func (az *AzureCSBatchSynthesis) definition(audioOutput AudioType, req BatchSynthesisRequest) (*batchSynthesisDefinition, error) {
+	if len(req.Inputs) == 0 {
+		return nil, fmt.Errorf("no batch synthesis inputs given")
+	}
+	d := &batchSynthesisDefinition{
+		Description: req.Description,
+		InputKind:   "PlainText",
+		Properties: batchSynthesisDefinitionOpts{
+			OutputFormat:            az.client.outputFormat(audioOutput).String(),
+			WordBoundaryEnabled:     req.WordBoundaryEnabled,
+			SentenceBoundaryEnabled: req.SentenceBoundaryEnabled,
+			ConcatenateResult:       req.ConcatenateResult,
+			DecompressOutputFiles:   req.DecompressOutputFiles,
+			DestinationContainerURL: req.DestinationContainerURL,
+			DestinationPath:         req.DestinationPath,
+			TimeToLiveInHours:       int((req.TimeToLive + time.Hour - 1) / time.Hour),
+		},
+	}
+	isSsml := req.Inputs[0].isSsml
+	for i, in := range req.Inputs {
+		if in.isSsml != isSsml {
+			return nil, fmt.Errorf("batch synthesis inputs must be all plain text or all SSML, input %d differs", i+1)
+		}
+		content, err := in.content()
+		if err != nil {
+			return nil, err
+		}
+		d.Inputs = append(d.Inputs, batchSynthesisInput{Content: content})
+	}
+
+	if isSsml {
+		d.InputKind = "SSML"
+		return d, nil
+	}
+	voice := req.Voice
+	if voice == "" {
+		if voice = az.client.opts.defaultVoice; voice == "" {
+			return nil, fmt.Errorf("no voice name given and no default voice set")
+		}
+	}
+	d.SynthesisConfig = &batchSynthesisConfig{Voice: voice}
+	return d, nil
+}
+
+// Get returns the current state of the job `id`.
+func (az *AzureCSBatchSynthesis) Get(ctx context.Context, id string) (*BatchSynthesisJob, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return az.get(ctx, id)
+}
+
+func (az *AzureCSBatchSynthesis) get(ctx context.Context, id string) (*BatchSynthesisJob, error) {
+	var job BatchSynthesisJob
+	if err := az.call(ctx, "get", http.MethodGet, az.jobURL(id), nil, &job, http.StatusOK); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Wait polls the job `id` until it has finished, first after the initial interval of
+// WithBatchPollInterval and then at doubling intervals up to its maximum. A failed job is returned
+// along with an error wrapping its BatchSynthesisError.
+func (az *AzureCSBatchSynthesis) Wait(ctx context.Context, id string) (*BatchSynthesisJob, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	delay := az.client.opts.batchPollInterval
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		job, err := az.get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch job.Status {
+		case BatchSynthesisSucceeded:
+			return job, nil
+		case BatchSynthesisFailed:
+			if job.Properties.Error != nil {
+				return job, fmt.Errorf("batch synthesis %s failed, %w", id, job.Properties.Error)
+			}
+			return job, fmt.Errorf("batch synthesis %s failed, %d of %d inputs failed", id,
+				job.Properties.FailedAudioCount, job.Properties.FailedAudioCount+job.Properties.SucceededAudioCount)
+		}
+		if delay = delay * 2; delay > az.client.opts.batchPollMaxInterval {
+			delay = az.client.opts.batchPollMaxInterval
+		}
+		timer.Reset(delay)
+	}
+}
+
+// List returns the jobs of the resource, following the pages of the listing.
+func (az *AzureCSBatchSynthesis) List(ctx context.Context) ([]BatchSynthesisJob, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	var jobs []BatchSynthesisJob
+	next := az.jobURL("")
+	for next != "" {
+		var page struct {
+			Value    []BatchSynthesisJob `json:"value"`
+			NextLink string              `json:"nextLink"`
+		}
+		if err := az.call(ctx, "list", http.MethodGet, next, nil, &page, http.StatusOK); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, page.Value...)
+		next = page.NextLink
+	}
+	return jobs, nil
+}
+
+// Delete removes the job `id` along with its results. Running jobs cannot be deleted.
+func (az *AzureCSBatchSynthesis) Delete(ctx context.Context, id string) error {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	return az.call(ctx, "delete", http.MethodDelete, az.jobURL(id), nil, nil, http.StatusOK, http.StatusNoContent)
+}
+
+// DownloadResults downloads the zip file of the results of the succeeded `job` and returns the files
+// it holds, sorted by name. Jobs writing to a DestinationContainerURL have no results to download.
+func (az *AzureCSBatchSynthesis) DownloadResults(ctx context.Context, job *BatchSynthesisJob) (_ []BatchResultFile, err error) {
+	if job.Status != BatchSynthesisSucceeded {
+		return nil, fmt.Errorf("batch synthesis %s has not succeeded, its status is %s", job.ID, job.Status)
+	}
+	if job.Outputs.Result == "" {
+		return nil, fmt.Errorf("batch synthesis %s has no results to download", job.ID)
+	}
+
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	ctx, op := az.client.opts.telemetry.start(ctx, "batch_synthesis_download")
+	defer func() { op.end(err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, job.Outputs.Result, nil)
+	if err != nil {
+		return nil, err
+	}
+	if az.client.opts.userAgent != "" {
+		req.Header.Set("User-Agent", az.client.opts.userAgent)
+	}
+	// the result is a SAS URL of the service's storage; the credentials are not sent there.
+	res, err := az.client.opts.retryPolicy.do(req, az.client.roundTrip)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	op.setResponse(res)
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError("batch synthesis download", res, nil)
+	}
+
+	archive, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download batch synthesis results, %w", err)
+	}
+	op.setSpanAttributes(attrBytes.Int(len(archive)))
+	return unzipBatchResults(archive)
+}
+
+// unzipBatchResults returns the files of the zip file `archive`, sorted by name.
+func unzipBatchResults(archive []byte) ([]BatchResultFile, error) {
+	r, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch synthesis results, %w", err)
+	}
+	var files []BatchResultFile
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s from batch synthesis results, %w", f.Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract %s from batch synthesis results, %w", f.Name, err)
+		}
+		files = append(files, BatchResultFile{Name: f.Name, Data: data})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+// jobURL returns the URL of the job `id`, or of the job listing when `id` is empty.
+func (az *AzureCSBatchSynthesis) jobURL(id string) string {
+	u, err := url.Parse(az.endpoint)
+	if err != nil {
+		// left for the request to report.
+		return az.endpoint
+	}
+	if id != "" {
+		u = u.JoinPath(id)
+	}
+	q := u.Query()
+	q.Set("api-version", batchSynthesisAPIVersion)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// call sends a request with the JSON `body` to the batch synthesis API and decodes the response into
+// `out`, unless nil. Responses with a status other than `statuses` are returned as an APIError.
+func (az *AzureCSBatchSynthesis) call(ctx context.Context, name string, method string, endpoint string, body []byte, out any, statuses ...int) (err error) {
+	ctx, op := az.client.opts.telemetry.start(ctx, "batch_synthesis_"+name)
+	defer func() { op.end(err) }()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := az.client.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	op.setResponse(res)
+
+	if !slices.Contains(statuses, res.StatusCode) {
+		return newAPIError("batch synthesis "+name, res, batchSynthesisStatusDescriptions)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode batch synthesis response body, %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package azure_cs_sdk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ho-229/azure-cs-sdk/ssml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBatchServer stands in for the batch synthesis API. Jobs advance one status per poll, and the
+// job "broken" fails.
+type testBatchServer struct {
+	*httptest.Server
+	mu          sync.Mutex
+	jobs        map[string]*BatchSynthesisJob
+	definitions map[string]batchSynthesisDefinition
+	polls       int
+}
+
+func newTestBatchServer(t *testing.T) *testBatchServer {
+	srv := &testBatchServer{jobs: map[string]*BatchSynthesisJob{}, definitions: map[string]batchSynthesisDefinition{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/batchsyntheses/", srv.serveJob)
+	mux.HandleFunc("/batchsyntheses", srv.serveList)
+	mux.HandleFunc("/results.zip", func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "credentials must not be sent to the result URL")
+		w.Write(makeZip(t, map[string]string{"0001.wav": "audio1", "0002.wav": "audio2", "summary.json": "{}"}))
+	})
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/results") {
+			assert.Equal(t, batchSynthesisAPIVersion, r.URL.Query().Get("api-version"))
+			assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func (srv *testBatchServer) serveJob(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	id := strings.TrimPrefix(r.URL.Path, "/batchsyntheses/")
+	job, ok := srv.jobs[id]
+	switch r.Method {
+	case http.MethodPut:
+		var definition batchSynthesisDefinition
+		if err := json.NewDecoder(r.Body).Decode(&definition); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		srv.definitions[id] = definition
+		job = &BatchSynthesisJob{ID: id, Description: definition.Description, Status: BatchSynthesisNotStarted, CreatedDateTime: time.Now().UTC()}
+		srv.jobs[id] = job
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"code": "NotFound", "message": "no such job"})
+			return
+		}
+		srv.polls++
+		switch job.Status {
+		case BatchSynthesisNotStarted:
+			job.Status = BatchSynthesisRunning
+		case BatchSynthesisRunning:
+			if id == "broken" {
+				job.Status = BatchSynthesisFailed
+				job.Properties.Error = &BatchSynthesisError{Code: "InvalidRequest", Message: "bad input"}
+			} else {
+				job.Status = BatchSynthesisSucceeded
+				job.Properties.SucceededAudioCount = len(srv.definitions[id].Inputs)
+				job.Outputs.Result = srv.URL + "/results.zip?sig=secret"
+			}
+		}
+	case http.MethodDelete:
+		delete(srv.jobs, id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(job)
+}
+
+// serveList returns one job per page.
+func (srv *testBatchServer) serveList(w http.ResponseWriter, r *http.Request) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	var ids []string
+	for id := range srv.jobs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	page := map[string]any{"value": []BatchSynthesisJob{}}
+	skip := 0
+	if r.URL.Query().Get("skip") == "1" {
+		skip = 1
+	}
+	if skip < len(ids) {
+		page["value"] = []*BatchSynthesisJob{srv.jobs[ids[skip]]}
+	}
+	if skip == 0 && len(ids) > 1 {
+		page["nextLink"] = srv.URL + "/batchsyntheses?skip=1&api-version=" + batchSynthesisAPIVersion
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func newTestBatchClient(t *testing.T, srv *testBatchServer, opts ...ClientOption) (*AzureCS, *AzureCSBatchSynthesis) {
+	opts = append([]ClientOption{
+		WithLazyInit(),
+		WithBatchSynthesisAPI(srv.URL + "/batchsyntheses"),
+		WithBatchPollInterval(time.Millisecond, 4*time.Millisecond),
+	}, opts...)
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, opts...)
+	require.NoError(t, err)
+	batch, err := az.NewBatchSynthesis()
+	require.NoError(t, err)
+	return az, batch
+}
+
+func TestBatchSynthesis(t *testing.T) {
+	srv := newTestBatchServer(t)
+	az, batch := newTestBatchClient(t, srv, WithDefaultVoice("en-US-JennyNeural"))
+	ctx := context.Background()
+
+	job, err := batch.Create(ctx, "book-1", AUDIO24khz48kbitrateMonoMP3, BatchSynthesisRequest{
+		Description:             "chapter one",
+		Inputs:                  []BatchInput{BatchText("Once upon a time."), BatchText("The end.")},
+		WordBoundaryEnabled:     true,
+		SentenceBoundaryEnabled: true,
+		TimeToLive:              90 * time.Minute,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "book-1", job.ID)
+	assert.Equal(t, BatchSynthesisNotStarted, job.Status)
+
+	definition := srv.definitions["book-1"]
+	assert.Equal(t, "PlainText", definition.InputKind)
+	require.NotNil(t, definition.SynthesisConfig)
+	assert.Equal(t, "en-US-JennyNeural", definition.SynthesisConfig.Voice)
+	assert.Equal(t, []batchSynthesisInput{{"Once upon a time."}, {"The end."}}, definition.Inputs)
+	assert.Equal(t, batchSynthesisDefinitionOpts{
+		OutputFormat:            AUDIO24khz48kbitrateMonoMP3.String(),
+		WordBoundaryEnabled:     true,
+		SentenceBoundaryEnabled: true,
+		TimeToLiveInHours:       2,
+	}, definition.Properties)
+
+	job, err = batch.Wait(ctx, "book-1")
+	require.NoError(t, err)
+	assert.Equal(t, BatchSynthesisSucceeded, job.Status)
+	assert.Equal(t, 2, job.Properties.SucceededAudioCount)
+	assert.Equal(t, 2, srv.polls)
+
+	files, err := batch.DownloadResults(ctx, job)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	assert.Equal(t, BatchResultFile{Name: "0001.wav", Data: []byte("audio1")}, files[0])
+	assert.Equal(t, "0002.wav", files[1].Name)
+	assert.Equal(t, "summary.json", files[2].Name)
+
+	_, err = batch.Create(ctx, "book-2", OGG24khz16bitMonoOpus, BatchSynthesisRequest{
+		Inputs: []BatchInput{BatchSsml(ssml.NewVoice("en-US-JennyNeural")), BatchRawSsml("<speak/>")},
+	})
+	require.NoError(t, err)
+	definition = srv.definitions["book-2"]
+	assert.Equal(t, "SSML", definition.InputKind)
+	assert.Nil(t, definition.SynthesisConfig)
+	assert.Contains(t, definition.Inputs[0].Content, `<voice name="en-US-JennyNeural">`)
+	assert.Equal(t, "<speak/>", definition.Inputs[1].Content)
+
+	jobs, err := batch.List(ctx)
+	require.NoError(t, err)
+	var ids []string
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	assert.ElementsMatch(t, []string{"book-1", "book-2"}, ids)
+
+	require.NoError(t, batch.Delete(ctx, "book-1"))
+	_, err = batch.Get(ctx, "book-1")
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "NotFound", apiErr.Code)
+	assert.Zero(t, az.lifecycle.outstanding())
+}
+
+func TestBatchSynthesisFailure(t *testing.T) {
+	srv := newTestBatchServer(t)
+	_, batch := newTestBatchClient(t, srv)
+	ctx := context.Background()
+
+	_, err := batch.Create(ctx, "broken", RIFF24khz16bitMonoPCM, BatchSynthesisRequest{Inputs: []BatchInput{BatchText("hi")}})
+	assert.ErrorContains(t, err, "no voice name")
+	_, err = batch.Create(ctx, "broken", RIFF24khz16bitMonoPCM, BatchSynthesisRequest{
+		Inputs: []BatchInput{BatchText("hi"), BatchRawSsml("<speak/>")},
+		Voice:  "en-US-JennyNeural",
+	})
+	assert.ErrorContains(t, err, "all plain text or all SSML")
+	_, err = batch.Create(ctx, "broken", RIFF24khz16bitMonoPCM, BatchSynthesisRequest{})
+	assert.Error(t, err)
+
+	_, err = batch.Create(ctx, "broken", RIFF24khz16bitMonoPCM, BatchSynthesisRequest{Inputs: []BatchInput{BatchText("hi")}, Voice: "en-US-JennyNeural"})
+	require.NoError(t, err)
+	job, err := batch.Wait(ctx, "broken")
+	var jobErr *BatchSynthesisError
+	require.ErrorAs(t, err, &jobErr)
+	assert.Equal(t, "InvalidRequest", jobErr.Code)
+	require.NotNil(t, job)
+	assert.Equal(t, BatchSynthesisFailed, job.Status)
+
+	_, err = batch.DownloadResults(ctx, job)
+	assert.ErrorContains(t, err, "has not succeeded")
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = batch.Wait(cancelled, "broken")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestNewBatchSynthesisContainer(t *testing.T) {
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2,
+		WithLazyInit(), WithEndpointResolver(ContainerEndpoints("http://localhost:5000")))
+	require.NoError(t, err)
+	_, err = az.NewBatchSynthesis()
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package azure_cs_sdk
+
+// Cloud references an Azure cloud. Sovereign clouds serve the Speech service from their own host suffixes.
+// See https://learn.microsoft.com/en-us/azure/ai-services/speech-service/sovereign-clouds
+//
+//go:generate enumer -type=Cloud -linecomment -json -trimprefix Cloud
+type Cloud int
+
+const (
+	CloudPublic Cloud = iota // Public
+	CloudUSGov               // USGov
+	CloudChina               // China
+)
+
+// cloudHosts holds the host suffixes and Microsoft Entra ID scope of a cloud.
+type cloudHosts struct {
+	speech     string // suffix of the tts/stt hosts, e.g. <region>.tts.speech.microsoft.com
+	api        string // suffix of the issueToken host, e.g. <region>.api.cognitive.microsoft.com
+	entraScope string
+}
+
+var cloudHostMap = map[Cloud]cloudHosts{
+	CloudPublic: {
+		speech:     "speech.microsoft.com",
+		api:        "api.cognitive.microsoft.com",
+		entraScope: "https://cognitiveservices.azure.com/.default",
+	},
+	CloudUSGov: {
+		speech:     "speech.azure.us",
+		api:        "api.cognitive.microsoft.us",
+		entraScope: "https://cognitiveservices.azure.us/.default",
+	},
+	CloudChina: {
+		speech:     "speech.azure.cn",
+		api:        "api.cognitive.azure.cn",
+		entraScope: "https://cognitiveservices.azure.cn/.default",
+	},
+}
+
+// hosts returns the host suffixes of the cloud, falling back to the public cloud for unknown values.
+func (c Cloud) hosts() cloudHosts {
+	if h, ok := cloudHostMap[c]; ok {
+		return h
+	}
+	return cloudHostMap[CloudPublic]
+}
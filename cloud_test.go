@@ -0,0 +1,47 @@
+package azure_cs_sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegionCloud(t *testing.T) {
+	assert.Equal(t, CloudPublic, RegionWestUS2.Cloud())
+	assert.Equal(t, CloudUSGov, RegionUSGovVirginia.Cloud())
+	assert.Equal(t, CloudChina, RegionChinaNorth2.Cloud())
+
+	region, err := RegionString("usgovarizona")
+	require.NoError(t, err)
+	assert.Equal(t, RegionUSGovArizona, region)
+}
+
+func TestSovereignCloudEndpoints(t *testing.T) {
+	endpoints, err := RegionEndpoints(RegionUSGovVirginia).ResolveEndpoints()
+	require.NoError(t, err)
+	assert.Equal(t, Endpoints{
+		TextToSpeech:   "https://USGovVirginia.tts.speech.azure.us/cognitiveservices",
+		TextToSpeechWS: "wss://USGovVirginia.tts.speech.azure.us/cognitiveservices/websocket/v1",
+		SpeechToText:   "https://USGovVirginia.stt.speech.azure.us/speech/recognition/conversation/cognitiveservices/v1",
+		SpeechToTextWS: "wss://USGovVirginia.stt.speech.azure.us/stt/speech/universal/v2",
+		TokenRefresh:   "https://USGovVirginia.api.cognitive.microsoft.us/sts/v1.0/issueToken",
+		BatchSynthesis: "https://USGovVirginia.api.cognitive.microsoft.us/texttospeech/batchsyntheses",
+	}, endpoints)
+
+	endpoints, err = RegionEndpoints(RegionChinaEast2).ResolveEndpoints()
+	require.NoError(t, err)
+	assert.Equal(t, Endpoints{
+		TextToSpeech:   "https://ChinaEast2.tts.speech.azure.cn/cognitiveservices",
+		TextToSpeechWS: "wss://ChinaEast2.tts.speech.azure.cn/cognitiveservices/websocket/v1",
+		SpeechToText:   "https://ChinaEast2.stt.speech.azure.cn/speech/recognition/conversation/cognitiveservices/v1",
+		SpeechToTextWS: "wss://ChinaEast2.stt.speech.azure.cn/stt/speech/universal/v2",
+		TokenRefresh:   "https://ChinaEast2.api.cognitive.azure.cn/sts/v1.0/issueToken",
+		BatchSynthesis: "https://ChinaEast2.api.cognitive.azure.cn/texttospeech/batchsyntheses",
+	}, endpoints)
+}
+
+func TestEntraIDTokenProviderForCloud(t *testing.T) {
+	p := NewEntraIDTokenProviderForCloud(CloudChina, nil, "")
+	assert.Equal(t, "https://cognitiveservices.azure.cn/.default", p.scope)
+}
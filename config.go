@@ -0,0 +1,319 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The environment variables read by NewFromEnvironment.
+const (
+	EnvSpeechKey      = "SPEECH_KEY"      // subscription key of the Speech resource.
+	EnvSpeechRegion   = "SPEECH_REGION"   // region of the resource, e.g. westus2.
+	EnvSpeechEndpoint = "SPEECH_ENDPOINT" // endpoint of a resource with a custom subdomain, used instead of the region.
+	EnvSpeechToken    = "SPEECH_TOKEN"    // pre-issued bearer token, used instead of the key.
+	EnvSpeechCloud    = "SPEECH_CLOUD"    // Azure cloud of the region: Public, USGov or China.
+	EnvSpeechConfig   = "SPEECH_CONFIG"   // path of a profile file.
+	EnvSpeechProfile  = "SPEECH_PROFILE"  // profile to load from the file.
+)
+
+// Config holds the settings of an AzureCS object, as loaded from the environment or a profile file.
+// Either Key or Token authenticates, and either Region or Endpoint locates the resource; Endpoint takes
+// precedence when both are set.
+type Config struct {
+	Key      string `json:"key,omitempty" yaml:"key,omitempty"`
+	Region   string `json:"region,omitempty" yaml:"region,omitempty"`
+	Endpoint string `json:"endpoint,omitempty" yaml:"endpoint,omitempty"`
+	Token    string `json:"token,omitempty" yaml:"token,omitempty"`
+	// Cloud is the Azure cloud whose hosts serve Region, e.g. USGov. It must match the region, and cannot be
+	// combined with Endpoint.
+	Cloud string `json:"cloud,omitempty" yaml:"cloud,omitempty"`
+	// DefaultVoice and DefaultOutputFormat become the defaults of the TTS clients, see WithDefaultVoice and
+	// WithDefaultOutputFormat. The output format is the X-Microsoft-OutputFormat name, e.g. riff-24khz-16bit-mono-pcm.
+	DefaultVoice        string       `json:"default_voice,omitempty" yaml:"default_voice,omitempty"`
+	DefaultOutputFormat string       `json:"default_output_format,omitempty" yaml:"default_output_format,omitempty"`
+	Retry               *RetryConfig `json:"retry,omitempty" yaml:"retry,omitempty"`
+
+	// origin names where each setting came from, for error messages.
+	origin map[string]string
+}
+
+// RetryConfig overrides the fields of DefaultRetryPolicy which are set. Backoffs are durations such as "500ms".
+type RetryConfig struct {
+	MaxAttempts    *int     `json:"max_attempts,omitempty" yaml:"max_attempts,omitempty"`
+	InitialBackoff string   `json:"initial_backoff,omitempty" yaml:"initial_backoff,omitempty"`
+	MaxBackoff     string   `json:"max_backoff,omitempty" yaml:"max_backoff,omitempty"`
+	Jitter         *float64 `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// profileFile is the layout of a profile file.
+type profileFile struct {
+	// DefaultProfile is loaded when no profile is named.
+	DefaultProfile string            `json:"default_profile" yaml:"default_profile"`
+	Profiles       map[string]Config `json:"profiles" yaml:"profiles"`
+}
+
+// ConfigError reports a missing or invalid setting.
+type ConfigError struct {
+	// Setting names the setting, e.g. SPEECH_REGION or `region of profile "prod" in speech.yaml`.
+	Setting string
+	Reason  string
+}
+
+// Error implements the error interface.
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid speech config: %s: %s", e.Setting, e.Reason)
+}
+
+// setting returns the name of the setting with the given key, e.g. "region", for error messages.
+func (c *Config) setting(key string) string {
+	if name, ok := c.origin[key]; ok {
+		return name
+	}
+	return key
+}
+
+func (c *Config) invalid(key string, format string, args ...any) error {
+	return &ConfigError{Setting: c.setting(key), Reason: fmt.Sprintf(format, args...)}
+}
+
+// LoadConfigFile loads the profile named `profile` from a JSON (.json) or YAML file. When `profile` is
+// empty, the file's default_profile is loaded, or its only profile. The file looks like:
+//
+//	default_profile: prod
+//	profiles:
+//	  prod:
+//	    key: ...
+//	    region: westus2
+//	    default_voice: en-US-JennyNeural
+//	    default_output_format: riff-24khz-16bit-mono-pcm
+//	    retry:
+//	      max_attempts: 5
+//	      initial_backoff: 250ms
+func LoadConfigFile(path string, profile string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read speech config file, %w", err)
+	}
+
+	var file profileFile
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&file)
+	}
+	if err != nil {
+		return Config{}, &ConfigError{Setting: path, Reason: err.Error()}
+	}
+
+	if profile == "" {
+		profile = file.DefaultProfile
+	}
+	if profile == "" {
+		if len(file.Profiles) != 1 {
+			return Config{}, &ConfigError{Setting: "default_profile in " + path, Reason: "no profile named and the file has more than one"}
+		}
+		for name := range file.Profiles {
+			profile = name
+		}
+	}
+	c, ok := file.Profiles[profile]
+	if !ok {
+		return Config{}, &ConfigError{Setting: "profiles in " + path, Reason: fmt.Sprintf("profile %q not found", profile)}
+	}
+
+	c.origin = make(map[string]string)
+	for _, key := range []string{"key", "region", "endpoint", "token", "cloud", "default_voice", "default_output_format",
+		"retry.max_attempts", "retry.initial_backoff", "retry.max_backoff", "retry.jitter"} {
+		c.origin[key] = fmt.Sprintf("%s of profile %q in %s", key, profile, path)
+	}
+	return c, nil
+}
+
+// ConfigFromEnvironment loads the profile file named by SPEECH_CONFIG, if set, and overrides its
+// settings with the SPEECH_KEY, SPEECH_REGION, SPEECH_ENDPOINT, SPEECH_TOKEN and SPEECH_CLOUD variables
+// which are set.
+func ConfigFromEnvironment() (Config, error) {
+	var c Config
+	if path := os.Getenv(EnvSpeechConfig); path != "" {
+		var err error
+		if c, err = LoadConfigFile(path, os.Getenv(EnvSpeechProfile)); err != nil {
+			return Config{}, err
+		}
+	} else if os.Getenv(EnvSpeechProfile) != "" {
+		return Config{}, &ConfigError{Setting: EnvSpeechConfig, Reason: EnvSpeechProfile + " is set but no profile file is given"}
+	}
+	if c.origin == nil {
+		c.origin = make(map[string]string)
+	}
+
+	for _, env := range []struct {
+		key   string
+		name  string
+		field *string
+	}{
+		{"key", EnvSpeechKey, &c.Key},
+		{"region", EnvSpeechRegion, &c.Region},
+		{"endpoint", EnvSpeechEndpoint, &c.Endpoint},
+		{"token", EnvSpeechToken, &c.Token},
+		{"cloud", EnvSpeechCloud, &c.Cloud},
+	} {
+		if value := os.Getenv(env.name); value != "" {
+			*env.field = value
+			c.origin[env.key] = env.name
+		} else if _, ok := c.origin[env.key]; !ok {
+			// a missing setting is reported under the variable which would provide it.
+			c.origin[env.key] = env.name
+		}
+	}
+	return c, nil
+}
+
+// NewFromEnvironment returns an AzureCS object configured by ConfigFromEnvironment. `opts` apply after
+// the configured settings.
+func NewFromEnvironment(client *http.Client, opts ...ClientOption) (*AzureCS, func(), error) {
+	c, err := ConfigFromEnvironment()
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewFromConfig(client, c, opts...)
+}
+
+// NewFromConfig returns an AzureCS object configured by `c`. `opts` apply after the configured settings.
+func NewFromConfig(client *http.Client, c Config, opts ...ClientOption) (*AzureCS, func(), error) {
+	configured, err := c.options()
+	if err != nil {
+		return nil, nil, err
+	}
+	opts = append(configured, opts...)
+
+	region := noRegion
+	if c.Endpoint == "" {
+		// validated by options.
+		region, _ = RegionString(c.Region)
+	}
+	if c.Token != "" {
+		return NewWithTokenProvider(client, NewStaticTokenProvider(c.Token), region, opts...)
+	}
+	return NewWithClient(client, c.Key, region, opts...)
+}
+
+// options validates `c` and translates it to ClientOptions.
+func (c *Config) options() ([]ClientOption, error) {
+	var opts []ClientOption
+
+	switch {
+	case c.Key == "" && c.Token == "":
+		return nil, &ConfigError{
+			Setting: fmt.Sprintf("%s or %s", c.setting("key"), c.setting("token")),
+			Reason:  "a subscription key or a token is required",
+		}
+	case c.Key != "" && c.Token != "":
+		return nil, &ConfigError{
+			Setting: fmt.Sprintf("%s and %s", c.setting("key"), c.setting("token")),
+			Reason:  "set either a subscription key or a token, not both",
+		}
+	}
+
+	var cloud Cloud
+	if c.Cloud != "" {
+		var err error
+		if cloud, err = CloudString(c.Cloud); err != nil {
+			return nil, c.invalid("cloud", "unknown cloud %q, expected one of %s", c.Cloud, strings.Join(CloudStrings(), ", "))
+		}
+	}
+
+	switch {
+	case c.Endpoint != "":
+		if c.Cloud != "" {
+			return nil, c.invalid("cloud", "an endpoint already names its hosts, set either a cloud or an endpoint, not both")
+		}
+		if _, err := parseResourceEndpoint(c.Endpoint); err != nil {
+			return nil, c.invalid("endpoint", "%v", err)
+		}
+		opts = append(opts, WithEndpointResolver(CustomDomainEndpoints(c.Endpoint)))
+	case c.Region == "":
+		return nil, &ConfigError{
+			Setting: fmt.Sprintf("%s or %s", c.setting("region"), c.setting("endpoint")),
+			Reason:  "a region or an endpoint is required",
+		}
+	default:
+		region, err := RegionString(c.Region)
+		if err != nil {
+			return nil, c.invalid("region", "unknown region %q", c.Region)
+		}
+		if c.Cloud != "" {
+			if region.Cloud() != cloud {
+				return nil, c.invalid("cloud", "region %s is in the %s cloud, not %s", region, region.Cloud(), cloud)
+			}
+			opts = append(opts, WithEndpointResolver(CloudEndpoints(cloud, region)))
+		}
+	}
+
+	if c.DefaultVoice != "" {
+		opts = append(opts, WithDefaultVoice(c.DefaultVoice))
+	}
+	if c.DefaultOutputFormat != "" {
+		format, err := AudioTypeString(c.DefaultOutputFormat)
+		if err != nil {
+			return nil, c.invalid("default_output_format", "unknown output format %q", c.DefaultOutputFormat)
+		}
+		opts = append(opts, WithDefaultOutputFormat(format))
+	}
+
+	if c.Retry != nil {
+		policy, err := c.retryPolicy()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithRetryPolicy(policy))
+	}
+	return opts, nil
+}
+
+// retryPolicy applies c.Retry over DefaultRetryPolicy.
+func (c *Config) retryPolicy() (RetryPolicy, error) {
+	policy := DefaultRetryPolicy()
+	r := c.Retry
+	if r.MaxAttempts != nil {
+		if *r.MaxAttempts < 1 {
+			return RetryPolicy{}, c.invalid("retry.max_attempts", "must be at least 1, got %d", *r.MaxAttempts)
+		}
+		policy.MaxAttempts = *r.MaxAttempts
+	}
+	for _, d := range []struct {
+		key   string
+		value string
+		field *time.Duration
+	}{
+		{"retry.initial_backoff", r.InitialBackoff, &policy.InitialBackoff},
+		{"retry.max_backoff", r.MaxBackoff, &policy.MaxBackoff},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration < 0 {
+			return RetryPolicy{}, c.invalid(d.key, "expected a non-negative duration such as 500ms, got %q", d.value)
+		}
+		*d.field = duration
+	}
+	if r.Jitter != nil {
+		if *r.Jitter < 0 || *r.Jitter > 1 {
+			return RetryPolicy{}, c.invalid("retry.jitter", "must be between 0 and 1, got %v", *r.Jitter)
+		}
+		policy.Jitter = *r.Jitter
+	}
+	return policy, nil
+}
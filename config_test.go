@@ -0,0 +1,153 @@
+package azure_cs_sdk
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearSpeechEnvironment unsets the variables read by ConfigFromEnvironment for the duration of the test.
+func clearSpeechEnvironment(t *testing.T) {
+	for _, name := range []string{EnvSpeechKey, EnvSpeechRegion, EnvSpeechEndpoint, EnvSpeechToken, EnvSpeechCloud, EnvSpeechConfig, EnvSpeechProfile} {
+		t.Setenv(name, "")
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestNewFromEnvironment(t *testing.T) {
+	clearSpeechEnvironment(t)
+	t.Setenv(EnvSpeechKey, "key")
+	t.Setenv(EnvSpeechRegion, "usgovvirginia")
+	t.Setenv(EnvSpeechCloud, "usgov")
+
+	az, cleanup, err := NewFromEnvironment(http.DefaultClient, WithLazyInit())
+	require.NoError(t, err)
+	defer cleanup()
+	assert.True(t, strings.EqualFold("https://usgovvirginia.tts.speech.azure.us/cognitiveservices", az.opts.textToSpeechAPI))
+	assert.True(t, strings.EqualFold("https://usgovvirginia.api.cognitive.microsoft.us/sts/v1.0/issueToken", az.opts.tokenRefreshAPI))
+}
+
+func TestConfigErrorsNameTheSetting(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		setting string
+	}{
+		{"no credentials", map[string]string{EnvSpeechRegion: "westus2"}, "SPEECH_KEY or SPEECH_TOKEN"},
+		{"key and token", map[string]string{EnvSpeechKey: "key", EnvSpeechToken: "token", EnvSpeechRegion: "westus2"}, "SPEECH_KEY and SPEECH_TOKEN"},
+		{"no region", map[string]string{EnvSpeechKey: "key"}, "SPEECH_REGION or SPEECH_ENDPOINT"},
+		{"unknown region", map[string]string{EnvSpeechKey: "key", EnvSpeechRegion: "atlantis"}, "SPEECH_REGION"},
+		{"bad endpoint", map[string]string{EnvSpeechKey: "key", EnvSpeechEndpoint: "ftp://example.com"}, "SPEECH_ENDPOINT"},
+		{"unknown cloud", map[string]string{EnvSpeechKey: "key", EnvSpeechRegion: "westus2", EnvSpeechCloud: "moon"}, "SPEECH_CLOUD"},
+		{"cloud with endpoint", map[string]string{EnvSpeechKey: "key", EnvSpeechEndpoint: "https://my.cognitiveservices.azure.com", EnvSpeechCloud: "USGov"}, "SPEECH_CLOUD"},
+		{"cloud mismatch", map[string]string{EnvSpeechKey: "key", EnvSpeechRegion: "westus2", EnvSpeechCloud: "China"}, "SPEECH_CLOUD"},
+		{"profile without file", map[string]string{EnvSpeechKey: "key", EnvSpeechProfile: "prod"}, "SPEECH_CONFIG"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearSpeechEnvironment(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, _, err := NewFromEnvironment(http.DefaultClient, WithLazyInit())
+			var configErr *ConfigError
+			require.ErrorAs(t, err, &configErr)
+			assert.Equal(t, tt.setting, configErr.Setting)
+		})
+	}
+}
+
+func TestLoadConfigFileProfiles(t *testing.T) {
+	path := writeConfigFile(t, "speech.yaml", `
+default_profile: prod
+profiles:
+  prod:
+    key: prod-key
+    region: westus2
+    default_voice: en-US-JennyNeural
+    default_output_format: audio-24khz-48kbitrate-mono-mp3
+    retry:
+      max_attempts: 5
+      initial_backoff: 250ms
+  dev:
+    token: dev-token
+    endpoint: https://dev.cognitiveservices.azure.com
+`)
+
+	c, err := LoadConfigFile(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, "prod-key", c.Key)
+	az, cleanup, err := NewFromConfig(http.DefaultClient, c, WithLazyInit())
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Equal(t, 5, az.opts.retryPolicy.MaxAttempts)
+	assert.Equal(t, 250*time.Millisecond, az.opts.retryPolicy.InitialBackoff)
+	assert.Equal(t, DefaultRetryPolicy().MaxBackoff, az.opts.retryPolicy.MaxBackoff)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	assert.Equal(t, "en-US-JennyNeural", tts.DefaultVoice())
+	assert.Equal(t, AUDIO24khz48kbitrateMonoMP3, tts.DefaultOutputFormat())
+
+	clearSpeechEnvironment(t)
+	t.Setenv(EnvSpeechConfig, path)
+	t.Setenv(EnvSpeechProfile, "dev")
+	az, cleanup, err = NewFromEnvironment(http.DefaultClient, WithLazyInit())
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Equal(t, "https://dev.cognitiveservices.azure.com/tts/cognitiveservices", az.opts.textToSpeechAPI)
+	assert.IsType(t, &StaticTokenProvider{}, az.tokenProvider)
+
+	// the environment overrides the file.
+	t.Setenv(EnvSpeechToken, "")
+	t.Setenv(EnvSpeechKey, "env-key")
+	c, err = ConfigFromEnvironment()
+	require.NoError(t, err)
+	assert.Equal(t, "env-key", c.Key)
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	yamlPath := writeConfigFile(t, "speech.yml", `
+profiles:
+  prod:
+    key: key
+    region: westus2
+    retry:
+      jitter: 2
+`)
+	c, err := LoadConfigFile(yamlPath, "")
+	require.NoError(t, err)
+	_, _, err = NewFromConfig(http.DefaultClient, c, WithLazyInit())
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr)
+	assert.Equal(t, `retry.jitter of profile "prod" in `+yamlPath, configErr.Setting)
+
+	_, err = LoadConfigFile(yamlPath, "staging")
+	require.ErrorAs(t, err, &configErr)
+	assert.Contains(t, configErr.Reason, `profile "staging" not found`)
+
+	jsonPath := writeConfigFile(t, "speech.json", `{"profiles": {"prod": {"key": "key", "regoin": "westus2"}}}`)
+	_, err = LoadConfigFile(jsonPath, "prod")
+	require.ErrorAs(t, err, &configErr)
+	assert.Contains(t, configErr.Reason, "regoin")
+}
+
+func TestAudioTypeString(t *testing.T) {
+	for _, format := range []AudioType{RAW16khz16bitMonoPCM, RIFF24khz16bitMonoPCM, OGG24khz16bitMonoOpus} {
+		parsed, err := AudioTypeString(format.String())
+		require.NoError(t, err)
+		assert.Equal(t, format, parsed)
+	}
+	_, err := AudioTypeString("wav")
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package azure_cs_sdk
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Endpoints holds the URLs of the Speech service APIs used by AzureCS.
+type Endpoints struct {
+	// TextToSpeech is the text-to-speech base URL. The synthesis (/v1) and voice list (/voices/list) paths are appended to it.
+	TextToSpeech string
+	// TextToSpeechWS is the URL of the text-to-speech websocket endpoint.
+	TextToSpeechWS string
+	// SpeechToText is the URL of the short audio speech-to-text REST endpoint.
+	SpeechToText string
+	// SpeechToTextWS is the URL of the speech-to-text websocket endpoint.
+	SpeechToTextWS string
+	// TokenRefresh is the URL of the issueToken endpoint.
+	TokenRefresh string
+	// BatchSynthesis is the URL of the batch synthesis jobs, to which the job ID is appended.
+	BatchSynthesis string
+}
+
+// EndpointResolver resolves the Speech service endpoints an AzureCS object talks to.
+type EndpointResolver interface {
+	ResolveEndpoints() (Endpoints, error)
+}
+
+// EndpointResolverFunc adapts a function to the EndpointResolver interface.
+type EndpointResolverFunc func() (Endpoints, error)
+
+// ResolveEndpoints calls f().
+func (f EndpointResolverFunc) ResolveEndpoints() (Endpoints, error) {
+	return f()
+}
+
+// RegionEndpoints resolves the regional endpoints, e.g. https://westus2.tts.speech.microsoft.com. This is the default.
+// Regions of sovereign clouds resolve to that cloud's hosts, e.g. https://usgovvirginia.tts.speech.azure.us.
+func RegionEndpoints(region Region) EndpointResolver {
+	return CloudEndpoints(region.Cloud(), region)
+}
+
+// CloudEndpoints resolves the endpoints of `region` on the hosts of `cloud`.
+func CloudEndpoints(cloud Cloud, region Region) EndpointResolver {
+	return EndpointResolverFunc(func() (Endpoints, error) {
+		hosts := cloud.hosts()
+		return Endpoints{
+			TextToSpeech:   fmt.Sprintf(textToSpeechAPI, region, hosts.speech),
+			TextToSpeechWS: fmt.Sprintf(textToSpeechWSAPI, region, hosts.speech),
+			SpeechToText:   fmt.Sprintf(speechToTextAPI, region, hosts.speech),
+			SpeechToTextWS: fmt.Sprintf(speechToTextWSAPI, region, hosts.speech),
+			TokenRefresh:   fmt.Sprintf(tokenRefreshAPI, region, hosts.api),
+			BatchSynthesis: fmt.Sprintf(batchSynthesisAPI, region, hosts.api),
+		}, nil
+	})
+}
+
+// CustomDomainEndpoints resolves the endpoints of a resource with a custom subdomain, such as
+// https://<name>.cognitiveservices.azure.com. The same layout is served through private endpoints,
+// whose DNS names resolve the custom subdomain to a private address.
+// See https://learn.microsoft.com/en-us/azure/ai-services/speech-service/speech-services-private-link
+func CustomDomainEndpoints(endpoint string) EndpointResolver {
+	return EndpointResolverFunc(func() (Endpoints, error) {
+		base, err := parseResourceEndpoint(endpoint)
+		if err != nil {
+			return Endpoints{}, err
+		}
+		ws := *base
+		ws.Scheme = "wss"
+
+		return Endpoints{
+			TextToSpeech:   base.JoinPath("tts", "cognitiveservices").String(),
+			TextToSpeechWS: ws.JoinPath("tts", "cognitiveservices", "websocket", "v1").String(),
+			SpeechToText:   base.JoinPath("stt", "speech", "recognition", "conversation", "cognitiveservices", "v1").String(),
+			SpeechToTextWS: ws.JoinPath("stt", "speech", "universal", "v2").String(),
+			TokenRefresh:   base.JoinPath("sts", "v1.0", "issueToken").String(),
+			BatchSynthesis: base.JoinPath("texttospeech", "batchsyntheses").String(),
+		}, nil
+	})
+}
+
+// ContainerEndpoints resolves the endpoints of an on-premises Speech container, e.g. http://localhost:5000.
+// Plain http hosts are paired with ws:// for the websocket endpoint, https hosts with wss://.
+// Containers do not issue tokens nor run batch synthesis, so TokenRefresh and BatchSynthesis are left empty.
+// See https://learn.microsoft.com/en-us/azure/ai-services/speech-service/speech-container-howto
+func ContainerEndpoints(host string) EndpointResolver {
+	return EndpointResolverFunc(func() (Endpoints, error) {
+		base, err := url.Parse(strings.TrimSpace(host))
+		if err != nil {
+			return Endpoints{}, fmt.Errorf("invalid container host %q, %v", host, err)
+		}
+		ws := *base
+		switch base.Scheme {
+		case "http":
+			ws.Scheme = "ws"
+		case "https":
+			ws.Scheme = "wss"
+		default:
+			return Endpoints{}, fmt.Errorf("invalid container host %q, scheme must be http or https", host)
+		}
+		if base.Host == "" {
+			return Endpoints{}, fmt.Errorf("invalid container host %q, host is missing", host)
+		}
+
+		return Endpoints{
+			TextToSpeech:   base.JoinPath("cognitiveservices").String(),
+			TextToSpeechWS: ws.JoinPath("cognitiveservices", "websocket", "v1").String(),
+			SpeechToText:   base.JoinPath("speech", "recognition", "conversation", "cognitiveservices", "v1").String(),
+			SpeechToTextWS: ws.JoinPath("speech", "universal", "v2").String(),
+		}, nil
+	})
+}
+
+// parseResourceEndpoint validates a resource endpoint such as https://<name>.cognitiveservices.azure.com/.
+func parseResourceEndpoint(endpoint string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("invalid resource endpoint %q, %v", endpoint, err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid resource endpoint %q, scheme must be https", endpoint)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid resource endpoint %q, host is missing", endpoint)
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u, nil
+}
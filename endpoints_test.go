@@ -0,0 +1,85 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomDomainEndpoints(t *testing.T) {
+	endpoints, err := CustomDomainEndpoints("https://my-speech.cognitiveservices.azure.com/").ResolveEndpoints()
+	require.NoError(t, err)
+	assert.Equal(t, Endpoints{
+		TextToSpeech:   "https://my-speech.cognitiveservices.azure.com/tts/cognitiveservices",
+		TextToSpeechWS: "wss://my-speech.cognitiveservices.azure.com/tts/cognitiveservices/websocket/v1",
+		SpeechToText:   "https://my-speech.cognitiveservices.azure.com/stt/speech/recognition/conversation/cognitiveservices/v1",
+		SpeechToTextWS: "wss://my-speech.cognitiveservices.azure.com/stt/speech/universal/v2",
+		TokenRefresh:   "https://my-speech.cognitiveservices.azure.com/sts/v1.0/issueToken",
+		BatchSynthesis: "https://my-speech.cognitiveservices.azure.com/texttospeech/batchsyntheses",
+	}, endpoints)
+}
+
+func TestCustomDomainEndpointsRejectsInvalidURL(t *testing.T) {
+	_, err := CustomDomainEndpoints("my-speech.cognitiveservices.azure.com").ResolveEndpoints()
+	require.Error(t, err)
+
+	_, _, err = NewWithEndpoint(http.DefaultClient, "key", "http://my-speech.cognitiveservices.azure.com", WithLazyInit())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "scheme must be https")
+}
+
+func TestEndpointOverridesTakePrecedence(t *testing.T) {
+	o, err := newClientOptions(RegionWestUS2, []ClientOption{
+		WithSpeechToTextWSAPI("ws://localhost:8080/ws"),
+		WithEndpointResolver(CustomDomainEndpoints("https://my-speech.cognitiveservices.azure.com")),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ws://localhost:8080/ws", o.speechToTextWSAPI)
+	assert.Equal(t, "https://my-speech.cognitiveservices.azure.com/tts/cognitiveservices", o.textToSpeechAPI)
+}
+
+func TestContainerEndpoints(t *testing.T) {
+	endpoints, err := ContainerEndpoints("http://localhost:5000").ResolveEndpoints()
+	require.NoError(t, err)
+	assert.Equal(t, Endpoints{
+		TextToSpeech:   "http://localhost:5000/cognitiveservices",
+		TextToSpeechWS: "ws://localhost:5000/cognitiveservices/websocket/v1",
+		SpeechToText:   "http://localhost:5000/speech/recognition/conversation/cognitiveservices/v1",
+		SpeechToTextWS: "ws://localhost:5000/speech/universal/v2",
+	}, endpoints)
+
+	_, err = ContainerEndpoints("ftp://localhost:5000").ResolveEndpoints()
+	require.Error(t, err)
+}
+
+func TestNewForContainerSendsNoCredentials(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"))
+		assert.Empty(t, r.Header.Get("Ocp-Apim-Subscription-Key"))
+		switch r.URL.Path {
+		case "/cognitiveservices/voices/list":
+			fmt.Fprintln(w, voiceListAPIGoodResponse)
+		case "/cognitiveservices/v1":
+			w.Write([]byte("audio"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	az, cleanup, err := NewForContainer(http.DefaultClient, ts.URL)
+	require.NoError(t, err)
+	defer cleanup()
+	assert.Nil(t, az.tokenRefreshDoneCh)
+
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	b, err := tts.SynthesizeWithContext(context.Background(), "hello", "ar-EG-Hoda", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+}
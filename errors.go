@@ -0,0 +1,167 @@
+package azure_cs_sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxErrorBodySize bounds how much of an error response body is read.
+const maxErrorBodySize = 4096
+
+// requestIDHeaders are the response headers which may carry the request ID, in order of preference.
+var requestIDHeaders = []string{"apim-request-id", "X-RequestId", "x-ms-request-id"}
+
+// responseRequestID returns the request ID carried by `header`, or an empty string.
+func responseRequestID(header http.Header) string {
+	for _, name := range requestIDHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// APIError is returned when the Speech service responds with an unexpected HTTP status. Use errors.As to
+// inspect it; the request ID is what Azure support asks for when opening a ticket.
+type APIError struct {
+	// Op names the failed operation, e.g. "synthesize" or "token refresh".
+	Op string
+	// StatusCode and Status are the HTTP status of the response.
+	StatusCode int
+	Status     string
+	// Code and Message are the service error parsed from the response body. When the body carries no
+	// message, Message describes the status code instead.
+	Code    string
+	Message string
+	// RequestID is the apim-request-id (or X-RequestId) response header.
+	RequestID string
+	// RetryAfter is the delay requested through the Retry-After header, or zero.
+	RetryAfter time.Duration
+	// Body is the start of the raw response body.
+	Body []byte
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	var b strings.Builder
+	if e.Op != "" {
+		b.WriteString(e.Op)
+		b.WriteString(": ")
+	}
+	fmt.Fprintf(&b, "%d - ", e.StatusCode)
+	if e.Code != "" {
+		b.WriteString(e.Code)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Message)
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request id: %s)", e.RequestID)
+	}
+	return b.String()
+}
+
+// Retryable reports whether the request may succeed when sent again, i.e. the service was throttling,
+// timed out or failed on its side.
+func (e *APIError) Retryable() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= http.StatusInternalServerError
+}
+
+// newAPIError builds an APIError from `res`, consuming up to maxErrorBodySize bytes of its body.
+// `descriptions` maps status codes to the fallback message used when the body carries none.
+func newAPIError(op string, res *http.Response, descriptions map[int]string) *APIError {
+	e := &APIError{
+		Op:         op,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+	}
+	e.RequestID = responseRequestID(res.Header)
+	if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+		e.RetryAfter = retryAfter
+	}
+
+	if res.Body != nil {
+		e.Body, _ = io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	}
+	e.Code, e.Message = parseServiceError(e.Body)
+	if e.Message == "" {
+		e.Message = descriptions[res.StatusCode]
+	}
+	if e.Message == "" {
+		e.Message = "received unexpected HTTP status code"
+	}
+	return e
+}
+
+// parseServiceError extracts the error code and message from a service error body. Both the
+// {"error":{"code":..,"message":..}} envelope and a bare {"code":..,"message":..} object are recognized;
+// any other non-empty body is used as the message.
+func parseServiceError(body []byte) (string, string) {
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		return "", ""
+	}
+
+	type serviceError struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	var envelope struct {
+		serviceError
+		Error *serviceError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil {
+		if envelope.Error != nil {
+			return envelope.Error.Code, envelope.Error.Message
+		}
+		if envelope.Code != "" || envelope.Message != "" {
+			return envelope.Code, envelope.Message
+		}
+	}
+	if strings.HasPrefix(text, "<") {
+		// HTML error pages from gateways carry no useful message.
+		return "", ""
+	}
+	return "", text
+}
+
+// Descriptions of the documented HTTP status codes of each API.
+// see: https://docs.microsoft.com/en-us/azure/cognitive-services/speech-service/rest-text-to-speech#http-status-codes-1
+var (
+	synthesizeStatusDescriptions = map[int]string{
+		http.StatusBadRequest:            "A required parameter is missing, empty, or null. Or, the value passed to either a required or optional parameter is invalid. A common issue is a header that is too long",
+		http.StatusUnauthorized:          "The request is not authorized. Check to make sure your subscription key or token is valid and in the correct region",
+		http.StatusRequestEntityTooLarge: "The SSML input is longer than 1024 characters",
+		http.StatusUnsupportedMediaType:  "It's possible that the wrong Content-Type was provided. Content-Type should be set to application/ssml+xml",
+		http.StatusTooManyRequests:       "You have exceeded the quota or rate of requests allowed for your subscription",
+		http.StatusBadGateway:            "Network or server-side issue. May also indicate invalid headers",
+	}
+	voiceListStatusDescriptions = map[int]string{
+		http.StatusBadRequest:      "A required parameter is missing, empty, or null. Or, the value passed to either a required or optional parameter is invalid. A common issue is a header that is too long",
+		http.StatusUnauthorized:    "The request is not authorized. Check to make sure your subscription key or token is valid and in the correct region",
+		http.StatusTooManyRequests: "You have exceeded the quota or rate of requests allowed for your subscription",
+		http.StatusBadGateway:      "Network or server-side issue. May also indicate invalid headers",
+	}
+	tokenStatusDescriptions = map[int]string{
+		http.StatusUnauthorized: "The subscription key is invalid or does not belong to this region or endpoint",
+		http.StatusForbidden:    "The subscription key is not allowed to issue tokens for this resource",
+	}
+	batchSynthesisStatusDescriptions = map[int]string{
+		http.StatusBadRequest:      "The job definition is invalid, e.g. an unsupported output format, an empty input or a malformed job ID",
+		http.StatusUnauthorized:    "The request is not authorized. Check to make sure your subscription key or token is valid and in the correct region",
+		http.StatusNotFound:        "The batch synthesis job was not found",
+		http.StatusTooManyRequests: "You have exceeded the quota or rate of requests allowed for your subscription",
+	}
+	recognizeStatusDescriptions = map[int]string{
+		http.StatusBadRequest:   "The language code wasn't provided, the language isn't supported, or the audio file is invalid",
+		http.StatusUnauthorized: "The request is not authorized. Check to make sure your subscription key or token is valid and in the correct region",
+		http.StatusForbidden:    "A subscription key or authorization token is missing",
+	}
+)
@@ -0,0 +1,97 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServiceError(t *testing.T) {
+	code, message := parseServiceError([]byte(`{"error":{"code":"InvalidRequest","message":"bad voice"}}`))
+	assert.Equal(t, "InvalidRequest", code)
+	assert.Equal(t, "bad voice", message)
+
+	code, message = parseServiceError([]byte(`{"code":"401","message":"Access denied"}`))
+	assert.Equal(t, "401", code)
+	assert.Equal(t, "Access denied", message)
+
+	code, message = parseServiceError([]byte("quota exceeded\n"))
+	assert.Empty(t, code)
+	assert.Equal(t, "quota exceeded", message)
+
+	code, message = parseServiceError([]byte("<html><body>502</body></html>"))
+	assert.Empty(t, code)
+	assert.Empty(t, message)
+}
+
+func TestAPIErrorFromSynthesize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apim-request-id", "req-123")
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	tts := &AzureCSTTS{
+		textToSpeechURL: ts.URL,
+		client:          &AzureCS{tokenProvider: NewStaticTokenProvider("token"), httpClient: http.DefaultClient},
+	}
+	_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.Error(t, err)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "synthesize", apiErr.Op)
+	assert.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	assert.Equal(t, "req-123", apiErr.RequestID)
+	assert.Equal(t, time.Second*2, apiErr.RetryAfter)
+	assert.True(t, apiErr.Retryable())
+	assert.Equal(t, "synthesize: 429 - You have exceeded the quota or rate of requests allowed for your subscription (request id: req-123)", err.Error())
+}
+
+func TestAPIErrorFromTokenRefresh(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("apim-request-id", "req-456")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"401","message":"Access denied due to invalid subscription key."}}`))
+	}))
+	defer ts.Close()
+
+	_, _, err := NewWithClient(http.DefaultClient, "key", RegionWestUS2, WithTokenRefreshAPI(ts.URL))
+	require.Error(t, err)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "token refresh", apiErr.Op)
+	assert.Equal(t, "Access denied due to invalid subscription key.", apiErr.Message)
+	assert.Equal(t, "req-456", apiErr.RequestID)
+	assert.False(t, apiErr.Retryable())
+}
+
+func TestAPIErrorFromWebsocketHandshake(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RequestId", "req-789")
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer ts.Close()
+
+	stt := &AzureCSSTT{
+		speechToTextWSAPI: strings.Replace(ts.URL, "http://", "ws://", 1),
+		client:            &AzureCS{tokenProvider: NewStaticTokenProvider("token")},
+	}
+	_, err := stt.Recognize(strings.NewReader("audio"), RIFF16khz16bitMonoPCM, []string{"en-US"})
+	require.Error(t, err)
+
+	var apiErr *APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, "websocket handshake", apiErr.Op)
+	assert.Equal(t, http.StatusForbidden, apiErr.StatusCode)
+	assert.Equal(t, "req-789", apiErr.RequestID)
+}
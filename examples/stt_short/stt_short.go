@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"os"
 
 	azure "github.com/ho-229/azure-cs-sdk"
@@ -16,17 +17,10 @@ func exit(err error) {
 }
 
 func main() {
-	var apiKey string
-	var region azure.Region
-	if apiKey = os.Getenv("AZUREKEY"); apiKey == "" {
-		exit(fmt.Errorf("please set your AZUREKEY environment variable"))
-	}
-	var err error
-	if region, err = azure.RegionString(os.Getenv("AZUREREGION")); err != nil {
-		exit(fmt.Errorf("please set your AZUREREGION environment variable"))
-	}
-
-	az, cleanup, err := azure.New(apiKey, region)
+	// create a key for "Cognitive Services" (kind=SpeechServices). Once the key is available
+	// in the Azure portal, push it and the resource's region into environment variables
+	// (export SPEECH_KEY=SYS64738 SPEECH_REGION=westus2).
+	az, cleanup, err := azure.NewFromEnvironment(http.DefaultClient)
 	if err != nil {
 		exit(fmt.Errorf("failed to create new client, received %v", err))
 	}
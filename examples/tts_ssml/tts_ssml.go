@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
+	"net/http"
 	"os"
 
 	azure "github.com/ho-229/azure-cs-sdk"
@@ -20,19 +21,9 @@ func exit(err error) {
 
 func main() {
 	// create a key for "Cognitive Services" (kind=SpeechServices). Once the key is available
-	// in the Azure portal, push it into an environment variable (export AZUREKEY=SYS64738).
-	// By default the free tier keys are served out of West US2
-	var apiKey string
-	var region azure.Region
-	if apiKey = os.Getenv("AZUREKEY"); apiKey == "" {
-		exit(fmt.Errorf("please set your AZUREKEY environment variable"))
-	}
-	var err error
-	if region, err = azure.RegionString(os.Getenv("AZUREREGION")); err != nil {
-		exit(fmt.Errorf("please set your AZUREREGION environment variable"))
-	}
-
-	az, cleanup, err := azure.New(apiKey, region)
+	// in the Azure portal, push it and the resource's region into environment variables
+	// (export SPEECH_KEY=SYS64738 SPEECH_REGION=westus2).
+	az, cleanup, err := azure.NewFromEnvironment(http.DefaultClient)
 	if err != nil {
 		exit(fmt.Errorf("failed to create new client, received %v", err))
 	}
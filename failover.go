@@ -0,0 +1,426 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// RegionCredential is the subscription key of a Speech resource together with the Region it lives in.
+type RegionCredential struct {
+	SubscriptionKey string
+	Region          Region
+	// Options apply to this region only, after the options shared by all regions, e.g. WithEndpointResolver
+	// for a resource behind a private endpoint.
+	Options []ClientOption
+}
+
+// CircuitBreaker controls when a FailoverClient stops sending calls to a failing region.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failed calls after which a region is skipped.
+	// Values below 1 are treated as 1.
+	FailureThreshold int
+	// Cooldown is how long a region is skipped before calls are sent to it again. A call which fails
+	// after the cooldown skips the region for another Cooldown straight away.
+	Cooldown time.Duration
+}
+
+// DefaultCircuitBreaker returns the breaker used by NewFailoverClient unless another is given: a region
+// is skipped for 30 seconds after 3 consecutive failures.
+func DefaultCircuitBreaker() CircuitBreaker {
+	return CircuitBreaker{FailureThreshold: 3, Cooldown: time.Second * 30}
+}
+
+// RegionHealth reports the circuit breaker state of one region of a FailoverClient.
+type RegionHealth struct {
+	Region Region
+	// Healthy is false while the region is skipped.
+	Healthy bool
+	// ConsecutiveFailures counts the failed calls since the last successful one.
+	ConsecutiveFailures int
+	// OpenUntil is when a skipped region is probed again; zero while Healthy.
+	OpenUntil time.Time
+}
+
+// breakerState tracks the consecutive failures of one member.
+type breakerState struct {
+	mu        sync.Mutex
+	breaker   CircuitBreaker
+	failures  int
+	openUntil time.Time
+}
+
+// available reports whether calls may be sent, i.e. the breaker is closed or its cooldown has elapsed.
+func (b *breakerState) available(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.openUntil)
+}
+
+func (b *breakerState) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// failure records a failed call, opening the breaker once the threshold is reached. A failed probe after
+// the cooldown opens it again straight away.
+func (b *breakerState) failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= max(b.breaker.FailureThreshold, 1) {
+		b.openUntil = now.Add(b.breaker.Cooldown)
+	}
+}
+
+func (b *breakerState) health(region Region, now time.Time) RegionHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	h := RegionHealth{Region: region, Healthy: !now.Before(b.openUntil), ConsecutiveFailures: b.failures}
+	if !h.Healthy {
+		h.OpenUntil = b.openUntil
+	}
+	return h
+}
+
+// isFailoverError reports whether a call which failed with `err` may succeed in another region: the
+// service was unreachable, failed on its side or throttled the call.
+func isFailoverError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrClientClosed) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+type servedRegionKey struct{}
+
+// ContextWithServedRegion returns a context which makes FailoverClient calls store the Region that served
+// them in `region`. For Recognize, the region is stored once the websocket session is open.
+func ContextWithServedRegion(ctx context.Context, region *Region) context.Context {
+	return context.WithValue(ctx, servedRegionKey{}, region)
+}
+
+func reportServedRegion(ctx context.Context, region Region) {
+	if r, ok := ctx.Value(servedRegionKey{}).(*Region); ok && r != nil {
+		*r = region
+	}
+}
+
+// member is a client of a FailoverClient or Pool together with its circuit breaker.
+type member struct {
+	client  *AzureCS
+	tts     *AzureCSTTS
+	stt     *AzureCSSTT
+	breaker breakerState
+	name    string    // names the member in errors, e.g. its region.
+	logAttr slog.Attr // identifies the member in failure logs.
+}
+
+func (m *member) base() *member {
+	return m
+}
+
+// tryMembers runs `call` against the members yielded by `next`, until it succeeds or fails with an error
+// another member cannot fix, and returns the member which succeeded. Failures count against the member's
+// circuit breaker and are logged as "<kind> failed". `replayable` is false when the call consumes input
+// which cannot be sent twice, in which case only one member is tried.
+func tryMembers[M interface{ base() *member }](ctx context.Context, kind string, replayable bool, next func() (M, bool), call func(M) error) (M, error) {
+	var errs []error
+	for m, ok := next(); ok; m, ok = next() {
+		b := m.base()
+		err := call(m)
+		if err == nil {
+			b.breaker.success()
+			return m, nil
+		}
+		if !isFailoverError(ctx, err) {
+			var zero M
+			return zero, err
+		}
+		b.breaker.failure(time.Now())
+		b.client.logger().WarnContext(ctx, kind+" failed", b.logAttr, "error", err)
+		errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+		if !replayable {
+			break
+		}
+	}
+	var zero M
+	return zero, fmt.Errorf("all %ss failed: %w", kind, errors.Join(errs...))
+}
+
+// failoverMember is one region of a FailoverClient.
+type failoverMember struct {
+	member
+	region Region
+}
+
+// FailoverClient spreads calls over the Speech resources of several regions, in the order given. A call
+// goes to the first region whose circuit breaker is closed and fails over to the next one when the
+// service cannot be reached, answers 5xx or throttles with 429. Other errors, such as a rejected SSML
+// document, are returned straight away.
+//
+// Every region is an AzureCS object built lazily, so that an outage does not prevent construction. Calls
+// are retried within a region according to its RetryPolicy before failing over; use
+// WithRetryPolicy(NoRetryPolicy()) to fail over immediately.
+type FailoverClient struct {
+	members []*failoverMember
+}
+
+// NewFailoverClient returns a FailoverClient for `credentials`, in order of preference. `opts` apply to
+// the client of every region.
+func NewFailoverClient(client *http.Client, credentials []RegionCredential, breaker CircuitBreaker, opts ...ClientOption) (*FailoverClient, error) {
+	if len(credentials) == 0 {
+		return nil, fmt.Errorf("at least one region is required")
+	}
+	c := &FailoverClient{}
+	for _, credential := range credentials {
+		regionOpts := append(append(append([]ClientOption{}, opts...), credential.Options...), WithLazyInit())
+		az, _, err := NewWithClient(client, credential.SubscriptionKey, credential.Region, regionOpts...)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to create the client for %s, %w", credential.Region, err)
+		}
+		tts, _ := az.NewTTS()
+		stt, _ := az.NewSTT()
+		c.members = append(c.members, &failoverMember{
+			member: member{
+				client:  az,
+				tts:     tts,
+				stt:     stt,
+				breaker: breakerState{breaker: breaker},
+				name:    credential.Region.String(),
+				logAttr: slog.String("region", credential.Region.String()),
+			},
+			region: credential.Region,
+		})
+	}
+	return c, nil
+}
+
+// Health reports the circuit breaker state of every region, in order of preference.
+func (c *FailoverClient) Health() []RegionHealth {
+	now := time.Now()
+	health := make([]RegionHealth, 0, len(c.members))
+	for _, m := range c.members {
+		health = append(health, m.breaker.health(m.region, now))
+	}
+	return health
+}
+
+// Shutdown shuts down the client of every region; see AzureCS.Shutdown.
+func (c *FailoverClient) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, m := range c.members {
+		errs = append(errs, m.client.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes the client of every region; see AzureCS.Close.
+func (c *FailoverClient) Close() error {
+	for _, m := range c.members {
+		m.client.Close()
+	}
+	return nil
+}
+
+// candidates returns the members to try, in order: the available ones, then those whose breaker is open
+// as a last resort.
+func (c *FailoverClient) candidates(now time.Time) []*failoverMember {
+	available := make([]*failoverMember, 0, len(c.members))
+	var open []*failoverMember
+	for _, m := range c.members {
+		if m.breaker.available(now) {
+			available = append(available, m)
+		} else {
+			open = append(open, m)
+		}
+	}
+	return append(available, open...)
+}
+
+// try runs `call` against the regions in order of preference; see tryMembers. The region which served
+// the call is reported to ContextWithServedRegion.
+func (c *FailoverClient) try(ctx context.Context, replayable bool, call func(m *failoverMember) error) error {
+	candidates := c.candidates(time.Now())
+	next := func() (*failoverMember, bool) {
+		if len(candidates) == 0 {
+			return nil, false
+		}
+		m := candidates[0]
+		candidates = candidates[1:]
+		return m, true
+	}
+	m, err := tryMembers(ctx, "region", replayable, next, call)
+	if err != nil {
+		return err
+	}
+	reportServedRegion(ctx, m.region)
+	return nil
+}
+
+// NewTTS returns a TTS client which fails over between the regions.
+func (c *FailoverClient) NewTTS() *FailoverTTS {
+	return &FailoverTTS{client: c}
+}
+
+// NewSTT returns a STT client which fails over between the regions.
+func (c *FailoverClient) NewSTT() *FailoverSTT {
+	return &FailoverSTT{client: c}
+}
+
+// FailoverTTS has the synthesis methods of AzureCSTTS and fails over between the regions of its
+// FailoverClient.
+type FailoverTTS struct {
+	client *FailoverClient
+}
+
+// Warmup fetches the token and voice list of every region. It only fails when no region could be warmed up.
+func (f *FailoverTTS) Warmup(ctx context.Context) error {
+	var errs []error
+	for _, m := range f.client.members {
+		err := m.tts.Warmup(ctx)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", m.region, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Synthesize is AzureCSTTS.Synthesize with failover.
+func (f *FailoverTTS) Synthesize(speechText string, voiceName string, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := f.client.try(context.Background(), true, func(m *failoverMember) (err error) {
+		audio, err = m.tts.Synthesize(speechText, voiceName, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// SynthesizeWithContext is AzureCSTTS.SynthesizeWithContext with failover.
+func (f *FailoverTTS) SynthesizeWithContext(ctx context.Context, speechText string, voiceName string, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := f.client.try(ctx, true, func(m *failoverMember) (err error) {
+		audio, err = m.tts.SynthesizeWithContext(ctx, speechText, voiceName, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// SynthesizeSsmlWithContext is AzureCSTTS.SynthesizeSsmlWithContext with failover.
+func (f *FailoverTTS) SynthesizeSsmlWithContext(ctx context.Context, elems xml.Token, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := f.client.try(ctx, true, func(m *failoverMember) (err error) {
+		audio, err = m.tts.SynthesizeSsmlWithContext(ctx, elems, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// SynthesizeRawSsmlWithContext is AzureCSTTS.SynthesizeRawSsmlWithContext with failover.
+func (f *FailoverTTS) SynthesizeRawSsmlWithContext(ctx context.Context, ssml string, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := f.client.try(ctx, true, func(m *failoverMember) (err error) {
+		audio, err = m.tts.SynthesizeRawSsmlWithContext(ctx, ssml, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// FailoverSTT has the recognition methods of AzureCSSTT and fails over between the regions of its
+// FailoverClient.
+type FailoverSTT struct {
+	client *FailoverClient
+}
+
+// RecognizeShortSimple is AzureCSSTT.RecognizeShortSimple with failover.
+func (f *FailoverSTT) RecognizeShortSimple(reader io.Reader, audioType AudioType, language string, opts ...Option) (*RecognizeSimpleResponse, error) {
+	return f.RecognizeShortSimpleWithContext(context.Background(), reader, audioType, language, opts...)
+}
+
+// RecognizeShortSimpleWithContext is AzureCSSTT.RecognizeShortSimpleWithContext with failover. The audio
+// can only be sent to another region when `reader` implements both io.ReaderAt and io.Seeker, like
+// *bytes.Reader and *os.File; otherwise only the first available region is tried.
+func (f *FailoverSTT) RecognizeShortSimpleWithContext(
+	ctx context.Context,
+	reader io.Reader,
+	audioType AudioType,
+	language string,
+	opts ...Option,
+) (*RecognizeSimpleResponse, error) {
+	replay, replayable := newReplayableReader(reader)
+	var resp *RecognizeSimpleResponse
+	err := f.client.try(ctx, replayable, func(m *failoverMember) (err error) {
+		resp, err = m.stt.RecognizeShortSimpleWithContext(ctx, replay(), audioType, language, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// Recognize is AzureCSSTT.Recognize with failover.
+func (f *FailoverSTT) Recognize(reader io.Reader, audioType AudioType, languages []string, opts ...Option) (<-chan RecognizeEvent, error) {
+	return f.RecognizeWithContext(context.Background(), reader, audioType, languages, opts...)
+}
+
+// RecognizeWithContext is AzureCSSTT.RecognizeWithContext with failover. Only opening the websocket
+// session fails over; once audio is streaming, errors are delivered on the events channel as usual.
+func (f *FailoverSTT) RecognizeWithContext(
+	ctx context.Context,
+	reader io.Reader,
+	audioType AudioType,
+	languages []string,
+	opts ...Option,
+) (<-chan RecognizeEvent, error) {
+	var events <-chan RecognizeEvent
+	err := f.client.try(ctx, true, func(m *failoverMember) (err error) {
+		events, err = m.stt.RecognizeWithContext(ctx, reader, audioType, languages, opts...)
+		return err
+	})
+	return events, err
+}
+
+// newReplayableReader returns a function yielding an independent reader over the remaining content of
+// `reader` for every attempt, when `reader` supports it. Each attempt reads through its own
+// io.SectionReader, so an attempt which is still being read by a transport cannot disturb the next one.
+func newReplayableReader(reader io.Reader) (func() io.Reader, bool) {
+	once := func() io.Reader { return reader }
+	at, ok := reader.(io.ReaderAt)
+	if !ok {
+		return once, false
+	}
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return once, false
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return once, false
+	}
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return once, false
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return once, false
+	}
+	return func() io.Reader {
+		return io.NewSectionReader(at, offset, size-offset)
+	}, true
+}
@@ -0,0 +1,99 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// regionOptions points a region of a FailoverClient at `srv`.
+func regionOptions(srv *httptest.Server) []ClientOption {
+	return []ClientOption{WithTokenRefreshAPI(srv.URL + "/token"), WithTextToSpeechAPI(srv.URL + "/tts")}
+}
+
+func TestFailoverClientFailsOverAndOpensBreaker(t *testing.T) {
+	var downCalls int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downCalls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := newTestSpeechServer(t, "token", nil)
+
+	c, err := NewFailoverClient(http.DefaultClient, []RegionCredential{
+		{SubscriptionKey: "key1", Region: RegionEastUS, Options: regionOptions(down)},
+		{SubscriptionKey: "key2", Region: RegionWestUS2, Options: regionOptions(up.Server)},
+	}, CircuitBreaker{FailureThreshold: 1, Cooldown: time.Minute}, WithRetryPolicy(NoRetryPolicy()))
+	require.NoError(t, err)
+	defer c.Close()
+	tts := c.NewTTS()
+
+	var served Region
+	ctx := ContextWithServedRegion(context.Background(), &served)
+	b, err := tts.SynthesizeRawSsmlWithContext(ctx, "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+	assert.Equal(t, RegionWestUS2, served)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downCalls))
+
+	health := c.Health()
+	require.Len(t, health, 2)
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, 1, health[0].ConsecutiveFailures)
+	assert.True(t, health[1].Healthy)
+
+	// the open breaker keeps calls away from the failing region.
+	_, err = tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&downCalls))
+}
+
+func TestFailoverClientReturnsClientErrors(t *testing.T) {
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Write([]byte("token"))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer bad.Close()
+	up := newTestSpeechServer(t, "token", nil)
+
+	c, err := NewFailoverClient(http.DefaultClient, []RegionCredential{
+		{SubscriptionKey: "key1", Region: RegionEastUS, Options: regionOptions(bad)},
+		{SubscriptionKey: "key2", Region: RegionWestUS2, Options: regionOptions(up.Server)},
+	}, DefaultCircuitBreaker())
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = c.NewTTS().SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Equal(t, int32(0), up.synthesizeCalls.Load())
+	assert.True(t, c.Health()[0].Healthy)
+}
+
+func TestNewReplayableReader(t *testing.T) {
+	r := bytes.NewReader([]byte("xxaudio"))
+	r.Seek(2, io.SeekStart)
+	replay, ok := newReplayableReader(r)
+	require.True(t, ok)
+	for i := 0; i < 2; i++ {
+		b, err := io.ReadAll(replay())
+		require.NoError(t, err)
+		assert.Equal(t, "audio", string(b))
+	}
+
+	_, ok = newReplayableReader(io.MultiReader(strings.NewReader("audio")))
+	assert.False(t, ok)
+}
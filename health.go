@@ -0,0 +1,237 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthReport describes whether an AzureCS object is usable, as returned by AzureCS.Health.
+type HealthReport struct {
+	// Healthy is true when the client is open, holds a valid credential and reached the service.
+	Healthy   bool      `json:"healthy"`
+	Closed    bool      `json:"closed,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+
+	Token     TokenHealth     `json:"token"`
+	VoiceList VoiceListHealth `json:"voice_list"`
+	Endpoint  EndpointHealth  `json:"endpoint"`
+}
+
+// TokenHealth reports the state of the client's credential.
+type TokenHealth struct {
+	// Valid is false when the TokenProvider's token is missing or expired. Providers whose credentials
+	// do not expire, such as NewSubscriptionKeyProvider, are always valid.
+	Valid bool `json:"valid"`
+	// IssuedAt, Age and ExpiresOn are zero when the provider does not report them.
+	IssuedAt  time.Time     `json:"issued_at"`
+	Age       time.Duration `json:"age,omitempty"`
+	ExpiresOn time.Time     `json:"expires_on"`
+	// LastRefresh is when the client last refreshed the token, in the background, on Warmup, after a 401
+	// or on demand for a request, and LastRefreshError the error of that refresh if it failed.
+	LastRefresh      time.Time `json:"last_refresh"`
+	LastRefreshError string    `json:"last_refresh_error,omitempty"`
+}
+
+// VoiceListHealth reports the freshness of the voice maps downloaded by the client's TTS objects.
+type VoiceListHealth struct {
+	// FetchedAt is when a voice list was last downloaded, zero if never.
+	FetchedAt time.Time     `json:"fetched_at"`
+	Age       time.Duration `json:"age,omitempty"`
+	Voices    int           `json:"voices,omitempty"`
+}
+
+// EndpointHealth reports the outcome of the authenticated voice list call made by Health.
+type EndpointHealth struct {
+	Reachable  bool          `json:"reachable"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// healthState records what Health reports about past calls.
+type healthState struct {
+	mu              sync.Mutex
+	lastRefresh     time.Time
+	lastRefreshErr  error
+	voicesFetchedAt time.Time
+	voices          int
+
+	// endpoint caches the last checkEndpoint result for healthProbeTTL.
+	endpoint          EndpointHealth
+	endpointCheckedAt time.Time
+}
+
+// healthProbeTTL is how long Health reuses the outcome of its voice list request, so that frequent probes
+// do not download the voice list each time.
+const healthProbeTTL = 5 * time.Second
+
+func (h *healthState) refreshed(now time.Time, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastRefresh = now
+	h.lastRefreshErr = err
+}
+
+// authorized records the outcome of attaching credentials to a request, which fetches a token on demand
+// when none is cached: a failure is a failed refresh, and a token issued after the last recorded refresh
+// is a successful one.
+func (h *healthState) authorized(provider TokenProvider, err error) {
+	if h == nil {
+		return
+	}
+	if err != nil {
+		h.refreshed(time.Now(), err)
+		return
+	}
+	issuer, ok := provider.(tokenIssuer)
+	if !ok {
+		return
+	}
+	issuedAt := issuer.IssuedAt()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if issuedAt.After(h.lastRefresh) {
+		h.lastRefresh = issuedAt
+		h.lastRefreshErr = nil
+	}
+}
+
+func (h *healthState) voicesFetched(now time.Time, voices int) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.voicesFetchedAt = now
+	h.voices = voices
+}
+
+// refresh refreshes the token through `refresher`, recording the outcome for Health.
+func (az *AzureCS) refresh(ctx context.Context, refresher tokenRefresher) error {
+	err := refresher.Refresh(ctx)
+	az.health.refreshed(time.Now(), err)
+	return err
+}
+
+// Health checks that the client is usable: it sends an authenticated request for the voice list, which
+// also fetches a token when none is cached, then reports the state of the token and voice maps. The
+// request is not retried, so that a probe fails fast; bound it with `ctx`. It bypasses the WithRateLimit
+// limiter, and its outcome is reused by calls within the next 5 seconds.
+func (az *AzureCS) Health(ctx context.Context) HealthReport {
+	report := HealthReport{CheckedAt: time.Now()}
+
+	ctx, end, err := az.begin(ctx)
+	if errors.Is(err, ErrClientClosed) {
+		report.Closed = true
+		report.Endpoint.Error = err.Error()
+	} else {
+		defer end()
+		report.Endpoint = az.probeEndpoint(ctx)
+	}
+
+	now := time.Now()
+	report.Token.Valid = true
+	if issuer, ok := az.tokenProvider.(tokenIssuer); ok {
+		if report.Token.IssuedAt = issuer.IssuedAt(); !report.Token.IssuedAt.IsZero() {
+			report.Token.Age = now.Sub(report.Token.IssuedAt)
+		}
+	}
+	if expirer, ok := az.tokenProvider.(tokenExpirer); ok {
+		report.Token.ExpiresOn = expirer.ExpiresOn()
+		report.Token.Valid = now.Before(report.Token.ExpiresOn)
+	}
+	if h := az.health; h != nil {
+		h.mu.Lock()
+		report.Token.LastRefresh = h.lastRefresh
+		if h.lastRefreshErr != nil {
+			report.Token.LastRefreshError = h.lastRefreshErr.Error()
+		}
+		if !h.voicesFetchedAt.IsZero() {
+			report.VoiceList = VoiceListHealth{FetchedAt: h.voicesFetchedAt, Age: now.Sub(h.voicesFetchedAt), Voices: h.voices}
+		}
+		h.mu.Unlock()
+	}
+
+	report.Healthy = !report.Closed && report.Token.Valid && report.Endpoint.Reachable
+	return report
+}
+
+// probeEndpoint returns the cached checkEndpoint result when it is younger than healthProbeTTL, and checks
+// the endpoint again otherwise.
+func (az *AzureCS) probeEndpoint(ctx context.Context) EndpointHealth {
+	h := az.health
+	if h == nil {
+		return az.checkEndpoint(ctx)
+	}
+	h.mu.Lock()
+	if !h.endpointCheckedAt.IsZero() && time.Since(h.endpointCheckedAt) < healthProbeTTL {
+		defer h.mu.Unlock()
+		return h.endpoint
+	}
+	h.mu.Unlock()
+
+	health := az.checkEndpoint(ctx)
+	if ctx.Err() == nil {
+		h.mu.Lock()
+		h.endpoint, h.endpointCheckedAt = health, time.Now()
+		h.mu.Unlock()
+	}
+	return health
+}
+
+// checkEndpoint sends a single authenticated voice list request, bypassing the rate limiter so that probes
+// do not compete with synthesis for its tokens.
+func (az *AzureCS) checkEndpoint(ctx context.Context) EndpointHealth {
+	var health EndpointHealth
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, az.opts.voiceListURL(), nil)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	if az.opts.userAgent != "" {
+		req.Header.Set("User-Agent", az.opts.userAgent)
+	}
+
+	start := time.Now()
+	res, err := az.sendAuthorized(req)
+	health.Latency = time.Since(start)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	defer res.Body.Close()
+	health.StatusCode = res.StatusCode
+	if res.StatusCode != http.StatusOK {
+		health.Error = newAPIError("voice list", res, voiceListStatusDescriptions).Error()
+		return health
+	}
+	health.Reachable = true
+	return health
+}
+
+// HealthHandler returns an http.Handler for readiness probes. It runs Health with the request's context
+// and writes the report as JSON, with status 200 when healthy and 503 otherwise.
+//
+// Each check downloads the voice list, which is several hundred KB, and counts against the service's
+// quota, although not against WithRateLimit. Health reuses a check for 5 seconds, so probing more often
+// than that costs nothing extra; probe intervals of that order or longer are recommended.
+func (az *AzureCS) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := az.Health(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if report.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
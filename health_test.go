@@ -0,0 +1,91 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthReportsTokenAndVoiceList(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	az := srv.newClient(t, "key", WithLazyInit())
+
+	report := az.Health(context.Background())
+	assert.True(t, report.Healthy)
+	assert.True(t, report.Token.Valid)
+	assert.False(t, report.Token.IssuedAt.IsZero())
+	assert.True(t, report.Token.ExpiresOn.After(report.Token.IssuedAt))
+	assert.Empty(t, report.Token.LastRefreshError)
+	assert.True(t, report.Endpoint.Reachable)
+	assert.Equal(t, http.StatusOK, report.Endpoint.StatusCode)
+	assert.True(t, report.VoiceList.FetchedAt.IsZero())
+	assert.EqualValues(t, 1, srv.tokenCalls.Load())
+
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	require.NoError(t, tts.Warmup(context.Background()))
+	report = az.Health(context.Background())
+	assert.False(t, report.VoiceList.FetchedAt.IsZero())
+	assert.Equal(t, 5, report.VoiceList.Voices)
+}
+
+func TestHealthReportsRefreshFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	az, cleanup, err := NewWithClient(http.DefaultClient, "bad-key", RegionWestUS2, WithLazyInit(),
+		WithTokenRefreshAPI(ts.URL+"/token"), WithTextToSpeechAPI(ts.URL+"/tts"), WithRetryPolicy(NoRetryPolicy()))
+	require.NoError(t, err)
+	defer cleanup()
+
+	report := az.Health(context.Background())
+	assert.False(t, report.Healthy)
+	assert.False(t, report.Token.Valid)
+	assert.Contains(t, report.Token.LastRefreshError, "401")
+	assert.False(t, report.Endpoint.Reachable)
+	assert.NotEmpty(t, report.Endpoint.Error)
+}
+
+func TestHealthHandler(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	az := srv.newClient(t, "key", WithLazyInit())
+
+	rec := httptest.NewRecorder()
+	az.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var report HealthReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.True(t, report.Healthy)
+
+	require.NoError(t, az.Close())
+	rec = httptest.NewRecorder()
+	az.HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	assert.True(t, report.Closed)
+}
+
+func TestHealthBypassesRateLimitAndReusesChecks(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	az := srv.newClient(t, "key", WithLazyInit(), WithRateLimit(0.001, 1))
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, az.Health(context.Background()).Endpoint.Reachable)
+	}
+	assert.EqualValues(t, 1, srv.voiceCalls.Load())
+
+	// the limiter's single token is still available to synthesis
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, az.limits.waitRequest(ctx))
+	assert.Zero(t, az.LimitStats().RateLimitWaits)
+}
@@ -0,0 +1,136 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// hedgePolicy is set by WithHedging.
+type hedgePolicy struct {
+	delay  time.Duration
+	target *AzureCS // nil sends the hedge through the client itself.
+}
+
+// HedgeStats reports how a single synthesis was hedged; see ContextWithHedgeStats.
+type HedgeStats struct {
+	// Fired is true when no response to the first request had arrived within the hedging delay and a
+	// second request was sent.
+	Fired bool
+	// HedgeWon is true when the audio came from the second request.
+	HedgeWon bool
+	// Duration is the time from the first request until the audio was received.
+	Duration time.Duration
+}
+
+type hedgeStatsKey struct{}
+
+// ContextWithHedgeStats returns a context which makes syntheses of a client configured with WithHedging
+// store how they were hedged in `stats`.
+func ContextWithHedgeStats(ctx context.Context, stats *HedgeStats) context.Context {
+	return context.WithValue(ctx, hedgeStatsKey{}, stats)
+}
+
+func reportHedgeStats(ctx context.Context, stats HedgeStats) {
+	if s, ok := ctx.Value(hedgeStatsKey{}).(*HedgeStats); ok && s != nil {
+		*s = stats
+	}
+}
+
+// synthesisResult is the outcome of one synthesis request.
+type synthesisResult struct {
+	audio    []byte
+	response *http.Response
+	err      error
+	hedge    bool
+}
+
+// synthesizeHedged sends `ssml` for synthesis. With a hedging policy, a second request goes to the hedge
+// target when no response has arrived within the delay; the first audio received in full is returned and
+// the other request is cancelled. A response arriving before the delay stops the hedge, however long its
+// audio takes to stream, and a request which fails before the delay is not hedged.
+func (az *AzureCSTTS) synthesizeHedged(ctx context.Context, ssml string, audioOutput AudioType) ([]byte, *http.Response, error) {
+	policy := az.client.opts.hedge
+	if policy == nil {
+		return az.client.synthesize(ctx, az.textToSpeechURL, ssml, audioOutput)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	answered := make(chan struct{}, 2)
+	results := make(chan synthesisResult, 2)
+	run := func(hedge bool, open func() (*http.Response, func(), error)) {
+		res, release, err := open()
+		if err != nil {
+			results <- synthesisResult{response: res, err: err, hedge: hedge}
+			return
+		}
+		defer release()
+		defer res.Body.Close()
+		answered <- struct{}{}
+		audio, err := io.ReadAll(res.Body)
+		results <- synthesisResult{audio: audio, response: res, err: err, hedge: hedge}
+	}
+	go run(false, func() (*http.Response, func(), error) {
+		res, err := az.client.openSynthesis(ctx, az.textToSpeechURL, ssml, audioOutput)
+		return res, func() {}, err
+	})
+
+	timer := time.NewTimer(policy.delay)
+	defer timer.Stop()
+	pending, fired := 1, false
+	var failed *synthesisResult
+	for {
+		select {
+		case <-answered:
+			timer.Stop()
+		case <-timer.C:
+			select {
+			case <-answered:
+				// the response arrived along with the timer.
+				continue
+			default:
+			}
+			fired = true
+			pending++
+			go run(true, func() (*http.Response, func(), error) {
+				return policy.open(ctx, az, ssml, audioOutput)
+			})
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				reportHedgeStats(ctx, HedgeStats{Fired: fired, HedgeWon: r.hedge, Duration: time.Since(start)})
+				return r.audio, r.response, nil
+			}
+			if failed == nil {
+				failed = &r
+			}
+			// a first request which failed before the delay is not hedged.
+			if pending == 0 {
+				reportHedgeStats(ctx, HedgeStats{Fired: fired, Duration: time.Since(start)})
+				return nil, failed.response, failed.err
+			}
+		}
+	}
+}
+
+// open sends the hedge request to the policy's target, or through `tts` itself, and returns the response
+// once its headers arrived. The returned function releases the target after the audio has been read.
+func (p *hedgePolicy) open(ctx context.Context, tts *AzureCSTTS, ssml string, audioOutput AudioType) (*http.Response, func(), error) {
+	if p.target == nil {
+		res, err := tts.client.openSynthesis(ctx, tts.textToSpeechURL, ssml, audioOutput)
+		return res, func() {}, err
+	}
+	ctx, end, err := p.target.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	res, err := p.target.openSynthesis(ctx, p.target.opts.synthesisURL(), ssml, audioOutput)
+	if err != nil {
+		end()
+		return res, nil, err
+	}
+	return res, end, nil
+}
@@ -0,0 +1,111 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgingTakesTheFasterResponse(t *testing.T) {
+	cancelled := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+			close(cancelled)
+		case <-time.After(5 * time.Second):
+			w.Write([]byte("slow"))
+		}
+	}))
+	defer slow.Close()
+	fast := newTestSpeechServer(t, "token", nil)
+
+	target := fast.newClient(t, "key", WithLazyInit())
+	az, cleanup, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2,
+		WithTextToSpeechAPI(slow.URL+"/tts"),
+		WithHedging(20*time.Millisecond, target),
+		WithLazyInit(),
+	)
+	require.NoError(t, err)
+	defer cleanup()
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	var stats HedgeStats
+	b, err := tts.SynthesizeRawSsmlWithContext(ContextWithHedgeStats(context.Background(), &stats), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+	assert.True(t, stats.Fired)
+	assert.True(t, stats.HedgeWon)
+	assert.Less(t, stats.Duration, 5*time.Second)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("the losing request was not cancelled")
+	}
+}
+
+func TestHedgingDoesNotFireForFastResponses(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	az := srv.newClient(t, "key", WithLazyInit(), WithHedging(time.Minute, nil))
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	var stats HedgeStats
+	_, err = tts.SynthesizeRawSsmlWithContext(ContextWithHedgeStats(context.Background(), &stats), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.False(t, stats.Fired)
+	assert.Equal(t, int32(1), srv.synthesizeCalls.Load())
+}
+
+func TestHedgingDoesNotFireForStreamingResponses(t *testing.T) {
+	var calls atomic.Int32
+	streaming := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+		// the headers arrive at once; the audio takes well past the hedging delay.
+		w.WriteHeader(http.StatusOK)
+		for _, chunk := range []string{"au", "di", "o"} {
+			w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+			time.Sleep(30 * time.Millisecond)
+		}
+	}))
+	defer streaming.Close()
+
+	az, cleanup, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2,
+		WithTextToSpeechAPI(streaming.URL+"/tts/"),
+		WithHedging(20*time.Millisecond, nil),
+		WithLazyInit(),
+	)
+	require.NoError(t, err)
+	defer cleanup()
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	var stats HedgeStats
+	b, err := tts.SynthesizeRawSsmlWithContext(ContextWithHedgeStats(context.Background(), &stats), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+	assert.False(t, stats.Fired)
+	assert.Greater(t, stats.Duration, 60*time.Millisecond)
+	assert.EqualValues(t, 1, calls.Load())
+}
+
+func TestHedgeTargetURL(t *testing.T) {
+	target, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2,
+		WithTextToSpeechAPI("https://example.com/tts/"), WithLazyInit())
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/tts/v1", target.opts.synthesisURL())
+	tts, err := target.NewTTS()
+	require.NoError(t, err)
+	assert.Equal(t, target.opts.synthesisURL(), tts.textToSpeechURL)
+}
@@ -0,0 +1,143 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClientClosed is returned by calls made after the AzureCS object was closed or shut down.
+var ErrClientClosed = errors.New("azure_cs_sdk: client is closed")
+
+// lifecycle tracks the calls in flight on an AzureCS object so that it can be shut down gracefully.
+type lifecycle struct {
+	mu     sync.Mutex
+	closed bool
+	active sync.WaitGroup
+	count  atomic.Int64 // the calls in `active`, for load balancing.
+
+	ctx       context.Context // cancelled to abort every call in flight.
+	cancelAll context.CancelFunc
+	stopOnce  sync.Once
+}
+
+func newLifecycle() *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{ctx: ctx, cancelAll: cancel}
+}
+
+// begin registers a call. The returned context is cancelled when `ctx` is done or when the client aborts
+// its calls on shutdown, and the returned function must be called once the call has finished.
+func (l *lifecycle) begin(ctx context.Context) (context.Context, func(), error) {
+	if l == nil {
+		return ctx, func() {}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, nil, ErrClientClosed
+	}
+	l.active.Add(1)
+	l.count.Add(1)
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(l.ctx, cancel)
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			l.count.Add(-1)
+			l.active.Done()
+		})
+	}, nil
+}
+
+// outstanding returns the number of calls in flight.
+func (l *lifecycle) outstanding() int64 {
+	if l == nil {
+		return 0
+	}
+	return l.count.Load()
+}
+
+// close rejects new calls.
+func (l *lifecycle) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+}
+
+// abort cancels every call in flight and waits for them to return.
+func (l *lifecycle) abort() {
+	if l == nil {
+		return
+	}
+	l.cancelAll()
+	l.active.Wait()
+}
+
+// wait blocks until every call in flight has finished, or `ctx` is done. In the latter case the calls
+// are cancelled and waited for before ctx.Err() is returned.
+func (l *lifecycle) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		l.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		select {
+		case <-done:
+			return nil
+		default:
+		}
+		l.abort()
+		return ctx.Err()
+	}
+}
+
+// Shutdown stops the token refresher and rejects new calls with ErrClientClosed, then waits for the
+// syntheses, recognitions and websocket sessions in flight to finish. When `ctx` is done first, the
+// remaining calls are cancelled and ctx.Err() is returned once they have returned.
+// It is safe to call Shutdown and Close more than once, and concurrently.
+func (az *AzureCS) Shutdown(ctx context.Context) error {
+	az.stop()
+	return az.lifecycle.wait(ctx)
+}
+
+// Close implements io.Closer. Unlike Shutdown, it cancels the calls in flight immediately, and returns
+// once they have returned.
+func (az *AzureCS) Close() error {
+	az.stop()
+	az.lifecycle.abort()
+	return nil
+}
+
+// stop rejects new calls and stops the token refresher.
+func (az *AzureCS) stop() {
+	if az.lifecycle == nil {
+		return
+	}
+	az.lifecycle.close()
+	az.lifecycle.stopOnce.Do(func() {
+		if az.tokenRefreshDoneCh != nil {
+			close(az.tokenRefreshDoneCh)
+		}
+	})
+}
+
+// begin registers a call on the client; see lifecycle.begin.
+func (az *AzureCS) begin(ctx context.Context) (context.Context, func(), error) {
+	return az.lifecycle.begin(ctx)
+}
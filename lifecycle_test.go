@@ -0,0 +1,142 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBlockingTTS returns a TTS client whose synthesis blocks until `release` is closed or the request is cancelled.
+func newBlockingTTS(t *testing.T, started chan<- struct{}, release <-chan struct{}) (*AzureCS, *AzureCSTTS) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// reading the body lets the server notice when the client goes away.
+		io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		select {
+		case <-release:
+			w.Write([]byte("audio"))
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, WithLazyInit())
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	tts.textToSpeechURL = ts.URL
+	return az, tts
+}
+
+func TestCloseIsIdempotentAndRejectsCalls(t *testing.T) {
+	az, cleanup, err := NewWithTokenProvider(http.DefaultClient, NewIssueTokenProvider(http.DefaultClient, "key", "http://127.0.0.1:0"), RegionWestUS2, WithLazyInit())
+	require.NoError(t, err)
+
+	cleanup()
+	require.NoError(t, az.Close())
+	require.NoError(t, az.Shutdown(context.Background()))
+
+	assert.ErrorIs(t, az.Warmup(context.Background()), ErrClientClosed)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	_, err = tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	assert.ErrorIs(t, err, ErrClientClosed)
+	stt, err := az.NewSTT()
+	require.NoError(t, err)
+	_, err = stt.RecognizeWithContext(context.Background(), nil, RIFF16khz16bitMonoPCM, []string{"en-US"})
+	assert.ErrorIs(t, err, ErrClientClosed)
+}
+
+func TestShutdownWaitsForCallsInFlight(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	az, tts := newBlockingTTS(t, started, release)
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+		result <- err
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- az.Shutdown(context.Background()) }()
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned while a synthesis was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-shutdown)
+	require.NoError(t, <-result)
+}
+
+func TestShutdownCancelsCallsWhenContextIsDone(t *testing.T) {
+	started := make(chan struct{}, 1)
+	az, tts := newBlockingTTS(t, started, make(chan struct{}))
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+		result <- err
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, az.Shutdown(ctx), context.DeadlineExceeded)
+	assert.ErrorIs(t, <-result, context.Canceled)
+}
+
+func TestCloseDoesNotWaitForUndrainedRecognition(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		hypothesis := buildWSTextFrame("speech.hypothesis", "reqid", "application/json", `{"Text":"hi"}`)
+		for i := 0; i < 32; i++ {
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(hypothesis)); err != nil {
+				return
+			}
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2)
+	require.NoError(t, err)
+	stt, err := az.NewSTT()
+	require.NoError(t, err)
+	stt.speechToTextWSAPI = strings.Replace(server.URL, "http://", "ws://", 1)
+
+	wav := append(make([]byte, wavHeaderSize), []byte("pcmdata")...)
+	_, err = stt.RecognizeWithContext(context.Background(), bytes.NewReader(wav), RIFF16khz16bitMonoPCM, []string{"en-US"})
+	require.NoError(t, err)
+
+	closed := make(chan struct{})
+	go func() {
+		az.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on a recognition whose events were never read")
+	}
+}
@@ -0,0 +1,189 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LimitStats reports how long calls have waited on the client-side limits of an AzureCS object.
+// The counters are cumulative since construction and suited to export as metrics.
+type LimitStats struct {
+	// RateLimitWaits is the number of HTTP request attempts, retries included, which had to wait for the
+	// rate limiter.
+	RateLimitWaits int64
+	// RateLimitWaitTime is the total time spent waiting for the rate limiter.
+	RateLimitWaitTime time.Duration
+	// RecognitionWaits is the number of websocket recognitions which had to wait for a free slot.
+	RecognitionWaits int64
+	// RecognitionWaitTime is the total time spent waiting for a free recognition slot.
+	RecognitionWaitTime time.Duration
+	// ActiveRecognitions is the number of websocket recognitions currently holding a slot.
+	ActiveRecognitions int64
+}
+
+// limits holds the client-side rate limiter and recognition semaphore of an AzureCS object.
+type limits struct {
+	limiter      *rateLimiter  // nil when unlimited
+	recognitions chan struct{} // nil when unlimited
+
+	rateLimitWaits      atomic.Int64
+	rateLimitWaitTime   atomic.Int64
+	recognitionWaits    atomic.Int64
+	recognitionWaitTime atomic.Int64
+	activeRecognitions  atomic.Int64
+}
+
+func newLimits(o clientOptions) *limits {
+	l := &limits{}
+	if o.rateLimit > 0 {
+		l.limiter = newRateLimiter(o.rateLimit, o.rateLimitBurst)
+	}
+	if o.maxConcurrentRecognitions > 0 {
+		l.recognitions = make(chan struct{}, o.maxConcurrentRecognitions)
+	}
+	return l
+}
+
+// waitRequest blocks until the rate limiter admits another HTTP request attempt, or `ctx` is done.
+func (l *limits) waitRequest(ctx context.Context) error {
+	if l == nil || l.limiter == nil {
+		return nil
+	}
+	waited, err := l.limiter.wait(ctx)
+	if waited > 0 {
+		l.rateLimitWaits.Add(1)
+		l.rateLimitWaitTime.Add(int64(waited))
+	}
+	return err
+}
+
+// acquireRecognition blocks until a websocket recognition slot is free, or `ctx` is done. The returned
+// function releases the slot.
+func (l *limits) acquireRecognition(ctx context.Context) (func(), error) {
+	if l == nil || l.recognitions == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case l.recognitions <- struct{}{}:
+	default:
+		start := time.Now()
+		select {
+		case l.recognitions <- struct{}{}:
+			l.recognitionWaits.Add(1)
+			l.recognitionWaitTime.Add(int64(time.Since(start)))
+		case <-ctx.Done():
+			l.recognitionWaits.Add(1)
+			l.recognitionWaitTime.Add(int64(time.Since(start)))
+			return nil, ctx.Err()
+		}
+	}
+
+	l.activeRecognitions.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.activeRecognitions.Add(-1)
+			<-l.recognitions
+		})
+	}, nil
+}
+
+// saturated reports whether a call would have to wait on the limits: the rate limiter has no token left
+// or, for a websocket `recognition`, every recognition slot is taken.
+func (l *limits) saturated(recognition bool) bool {
+	if l == nil {
+		return false
+	}
+	if recognition && l.recognitions != nil && len(l.recognitions) == cap(l.recognitions) {
+		return true
+	}
+	return l.limiter != nil && !l.limiter.available(time.Now())
+}
+
+func (l *limits) stats() LimitStats {
+	if l == nil {
+		return LimitStats{}
+	}
+	return LimitStats{
+		RateLimitWaits:      l.rateLimitWaits.Load(),
+		RateLimitWaitTime:   time.Duration(l.rateLimitWaitTime.Load()),
+		RecognitionWaits:    l.recognitionWaits.Load(),
+		RecognitionWaitTime: time.Duration(l.recognitionWaitTime.Load()),
+		ActiveRecognitions:  l.activeRecognitions.Load(),
+	}
+}
+
+// rateLimiter is a token bucket refilled at `rate` tokens per second up to `burst` tokens.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller must wait before using it.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// available reports whether a token can be taken without waiting.
+func (l *rateLimiter) available(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate) >= 1
+}
+
+// cancel returns a token taken by reserve which will not be used.
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// wait blocks until a token is available or `ctx` is done, and returns how long it waited.
+func (l *rateLimiter) wait(ctx context.Context) (time.Duration, error) {
+	delay := l.reserve(time.Now())
+	if delay <= 0 {
+		return 0, nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		l.cancel()
+		return 0, context.DeadlineExceeded
+	}
+
+	start := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		l.cancel()
+		return time.Since(start), ctx.Err()
+	case <-timer.C:
+		return time.Since(start), nil
+	}
+}
@@ -0,0 +1,112 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	l := newRateLimiter(10, 2)
+	now := l.last
+	assert.Equal(t, time.Duration(0), l.reserve(now))
+	assert.Equal(t, time.Duration(0), l.reserve(now))
+	assert.Equal(t, time.Millisecond*100, l.reserve(now))
+
+	// 200ms later both outstanding tokens have been refilled.
+	assert.Equal(t, time.Duration(0), l.reserve(now.Add(time.Millisecond*200)))
+}
+
+func TestLimitsWaitRequestHonorsContext(t *testing.T) {
+	l := newLimits(clientOptions{rateLimit: 1, rateLimitBurst: 1})
+	require.NoError(t, l.waitRequest(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err := l.waitRequest(ctx)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestLimitsWaitRequestRecordsWaitTime(t *testing.T) {
+	l := newLimits(clientOptions{rateLimit: 50, rateLimitBurst: 1})
+	require.NoError(t, l.waitRequest(context.Background()))
+	require.NoError(t, l.waitRequest(context.Background()))
+
+	stats := l.stats()
+	assert.Equal(t, int64(1), stats.RateLimitWaits)
+	assert.Greater(t, int64(stats.RateLimitWaitTime), int64(0))
+}
+
+func TestLimitsAcquireRecognition(t *testing.T) {
+	l := newLimits(clientOptions{maxConcurrentRecognitions: 1})
+	release, err := l.acquireRecognition(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), l.stats().ActiveRecognitions)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	_, err = l.acquireRecognition(ctx)
+	require.Error(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		second, err := l.acquireRecognition(context.Background())
+		assert.NoError(t, err)
+		second()
+		close(acquired)
+	}()
+	time.Sleep(time.Millisecond * 10)
+	release()
+	release() // releasing twice must not free a second slot.
+	<-acquired
+
+	stats := l.stats()
+	assert.Equal(t, int64(0), stats.ActiveRecognitions)
+	assert.Equal(t, int64(2), stats.RecognitionWaits)
+	assert.Greater(t, int64(stats.RecognitionWaitTime), int64(0))
+}
+
+func TestLimitsUnlimitedByDefault(t *testing.T) {
+	var l *limits
+	require.NoError(t, l.waitRequest(context.Background()))
+	release, err := l.acquireRecognition(context.Background())
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, LimitStats{}, l.stats())
+}
+
+func TestRateLimitCountsRetries(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer ts.Close()
+
+	o := clientOptions{retryPolicy: testRetryPolicy(), rateLimit: 50, rateLimitBurst: 1}
+	tts := &AzureCSTTS{
+		textToSpeechURL: ts.URL,
+		client: &AzureCS{
+			tokenProvider: NewStaticTokenProvider("token"),
+			httpClient:    http.DefaultClient,
+			opts:          o,
+			limits:        newLimits(o),
+		},
+	}
+	_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Equal(t, int64(2), tts.client.LimitStats().RateLimitWaits, "both retries must wait for the limiter")
+}
@@ -0,0 +1,98 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces secrets in log output.
+const redacted = "REDACTED"
+
+// secretHeaders are the headers whose values are never logged.
+var secretHeaders = []string{"Authorization", "Ocp-Apim-Subscription-Key"}
+
+// secretQueryParameters are the URL query parameters whose values are never logged.
+var secretQueryParameters = []string{"Authorization", "Ocp-Apim-Subscription-Key", "access_token", "token", "sig"}
+
+// discardHandler is an slog.Handler which drops every record.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// discardLogger is used where no logger was configured, e.g. for providers built outside of an AzureCS object.
+var discardLogger = slog.New(discardHandler{})
+
+// logger returns the configured logger, or one which discards everything.
+func (az *AzureCS) logger() *slog.Logger {
+	if az.opts.logger == nil {
+		return discardLogger
+	}
+	return az.opts.logger
+}
+
+// redactedHeader logs an http.Header with its credentials replaced.
+type redactedHeader http.Header
+
+// LogValue implements slog.LogValuer.
+func (h redactedHeader) LogValue() slog.Value {
+	attrs := make([]slog.Attr, 0, len(h))
+	for name, values := range h {
+		value := strings.Join(values, ", ")
+		if isSecretHeader(name) {
+			value = redacted
+		}
+		attrs = append(attrs, slog.String(name, value))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func isSecretHeader(name string) bool {
+	for _, secret := range secretHeaders {
+		if strings.EqualFold(name, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL returns `u` with the values of credential-bearing query parameters and any user info replaced.
+func redactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	clean := *u
+	if clean.User != nil {
+		clean.User = url.User(redacted)
+	}
+	if clean.RawQuery != "" {
+		query := clean.Query()
+		changed := false
+		for name := range query {
+			for _, secret := range secretQueryParameters {
+				if strings.EqualFold(name, secret) {
+					query.Set(name, redacted)
+					changed = true
+				}
+			}
+		}
+		if changed {
+			clean.RawQuery = query.Encode()
+		}
+	}
+	return clean.String()
+}
+
+// redactRawURL is redactURL for a URL which has not been parsed yet. Unparseable URLs are dropped entirely.
+func redactRawURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	return redactURL(u)
+}
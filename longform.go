@@ -0,0 +1,268 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultLongFormChunkSize is the default maximum number of characters of text synthesized per request.
+const defaultLongFormChunkSize = 1000
+
+// defaultLongFormConcurrency is the default number of chunks synthesized at once.
+const defaultLongFormConcurrency = 4
+
+// LongFormOption configures SynthesizeLongFormWithContext.
+type LongFormOption func(*longFormOptions)
+
+type longFormOptions struct {
+	chunkSize   int
+	concurrency int
+}
+
+// WithChunkSize sets the maximum number of characters of text synthesized per request, 1000 by default.
+func WithChunkSize(characters int) LongFormOption {
+	return func(o *longFormOptions) {
+		o.chunkSize = characters
+	}
+}
+
+// WithChunkConcurrency sets the number of chunks synthesized at once, 4 by default.
+func WithChunkConcurrency(n int) LongFormOption {
+	return func(o *longFormOptions) {
+		o.concurrency = n
+	}
+}
+
+// SynthesizeLongFormWithContext synthesizes text of any length. `speechText` is split at paragraph and
+// sentence boundaries into chunks under the chunk size, which are synthesized concurrently and stitched
+// into a single file of the `audioOutput` format. The first chunk which fails cancels the others.
+// Truesilk formats cannot be stitched and are rejected.
+func (az *AzureCSTTS) SynthesizeLongFormWithContext(ctx context.Context, speechText string, voiceName string, audioOutput AudioType, opts ...LongFormOption) ([]byte, error) {
+	params := longFormOptions{chunkSize: defaultLongFormChunkSize, concurrency: defaultLongFormConcurrency}
+	for _, opt := range opts {
+		opt(&params)
+	}
+	if params.chunkSize < 1 {
+		return nil, fmt.Errorf("chunk size must be at least 1, got %d", params.chunkSize)
+	}
+	if params.concurrency < 1 {
+		return nil, fmt.Errorf("chunk concurrency must be at least 1, got %d", params.concurrency)
+	}
+	audioOutput = az.client.outputFormat(audioOutput)
+	if !canStitchAudio(audioOutput) {
+		return nil, fmt.Errorf("long-form synthesis does not support the %s output format", audioOutput)
+	}
+	chunks := splitLongText(speechText, params.chunkSize)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no text to synthesize")
+	}
+
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parts := make([][]byte, len(chunks))
+	slots := make(chan struct{}, params.concurrency)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var chunkErr error
+dispatch:
+	for i, chunk := range chunks {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break dispatch
+		}
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			voice, err := az.textVoice(ctx, chunk, voiceName)
+			if err == nil {
+				parts[i], err = az.synthesizeSsml(ctx, voice, audioOutput)
+			}
+			if err != nil {
+				once.Do(func() {
+					chunkErr = fmt.Errorf("failed to synthesize chunk %d of %d, %w", i+1, len(chunks), err)
+					cancel()
+				})
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+	if chunkErr != nil {
+		return nil, chunkErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return stitchAudio(audioOutput, parts)
+}
+
+// splitLongText splits `text` into chunks of at most `size` characters. Chunks end at paragraph or
+// sentence boundaries; a sentence longer than `size` is split at clause punctuation or spaces, or
+// between characters as a last resort. Sentences end after an ASCII terminator followed by a space,
+// or after a CJK terminator, which needs none.
+func splitLongText(text string, size int) []string {
+	var chunks []string
+	var current strings.Builder
+	currentLen := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentLen = 0
+	}
+
+	for _, segment := range splitSentences(text) {
+		n := utf8.RuneCountInString(strings.TrimSpace(segment))
+		if n == 0 {
+			current.WriteString(segment)
+			continue
+		}
+		if currentLen > 0 && currentLen+utf8.RuneCountInString(strings.TrimRightFunc(segment, unicode.IsSpace)) > size {
+			flush()
+		}
+		if n > size {
+			chunks = append(chunks, splitOversized(strings.TrimSpace(segment), size)...)
+			continue
+		}
+		if currentLen == 0 {
+			segment = strings.TrimLeftFunc(segment, unicode.IsSpace)
+		}
+		current.WriteString(segment)
+		currentLen += utf8.RuneCountInString(segment)
+		if isParagraphEnd(segment) {
+			// a paragraph starts a chunk of its own when the current one is already half full.
+			if currentLen*2 >= size {
+				flush()
+			}
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitSentences splits `text` into sentences and paragraphs, each with its trailing whitespace, so
+// that they add up to `text`.
+func splitSentences(text string) []string {
+	var segments []string
+	runes := []rune(text)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		boundary := false
+		switch {
+		case isCJKTerminator(r):
+			i = skipClosers(runes, i)
+			boundary = true
+		case r == '.' || r == '!' || r == '?':
+			i = skipClosers(runes, i)
+			boundary = i+1 == len(runes) || unicode.IsSpace(runes[i+1])
+		case r == '\n':
+			boundary = isBlankLineAt(runes, i)
+		}
+		if !boundary {
+			continue
+		}
+		for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			i++
+		}
+		segments = append(segments, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		segments = append(segments, string(runes[start:]))
+	}
+	return segments
+}
+
+// splitOversized splits a sentence longer than `size` characters, preferring to cut after clause
+// punctuation or at spaces.
+func splitOversized(sentence string, size int) []string {
+	var pieces []string
+	runes := []rune(sentence)
+	for len(runes) > size {
+		cut := size
+		for i := size - 1; i > 0; i-- {
+			if isClauseBreak(runes[i]) {
+				cut = i + 1
+				break
+			}
+		}
+		if piece := strings.TrimSpace(string(runes[:cut])); piece != "" {
+			pieces = append(pieces, piece)
+		}
+		runes = []rune(strings.TrimLeftFunc(string(runes[cut:]), unicode.IsSpace))
+	}
+	if len(runes) > 0 {
+		pieces = append(pieces, string(runes))
+	}
+	return pieces
+}
+
+func isCJKTerminator(r rune) bool {
+	switch r {
+	case '。', '！', '？', '；', '…', '｡':
+		return true
+	}
+	return false
+}
+
+func isCloser(r rune) bool {
+	switch r {
+	case '"', '\'', ')', ']', '”', '’', '）', '」', '』', '】', '》', '〉':
+		return true
+	}
+	return false
+}
+
+func isClauseBreak(r rune) bool {
+	switch r {
+	case ',', ';', ':', '，', '、', '：', '；':
+		return true
+	}
+	return unicode.IsSpace(r)
+}
+
+// skipClosers returns the index of the last closing quote or bracket following the terminator at `i`,
+// or `i` when there is none. Repeated terminators, as in "?!" or "。。", are skipped as well.
+func skipClosers(runes []rune, i int) int {
+	for i+1 < len(runes) {
+		next := runes[i+1]
+		if !isCloser(next) && !isCJKTerminator(next) && next != '.' && next != '!' && next != '?' {
+			break
+		}
+		i++
+	}
+	return i
+}
+
+// isBlankLineAt reports whether the newline at `i` is followed by a blank line.
+func isBlankLineAt(runes []rune, i int) bool {
+	for j := i + 1; j < len(runes); j++ {
+		switch runes[j] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+			continue
+		}
+		return false
+	}
+	return false
+}
+
+// isParagraphEnd reports whether `segment` ends with a blank line.
+func isParagraphEnd(segment string) bool {
+	trailing := segment[len(strings.TrimRightFunc(segment, unicode.IsSpace)):]
+	return strings.Count(trailing, "\n") >= 2
+}
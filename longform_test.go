@@ -0,0 +1,114 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitLongText(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		want []string
+	}{
+		{"sentences", "First sentence. Second one! Third?", 20, []string{"First sentence.", "Second one! Third?"}},
+		{"cjk", "今天天气很好。我们去公园吧！好的。", 8, []string{"今天天气很好。", "我们去公园吧！", "好的。"}},
+		{"decimal", "Pi is 3.14 exactly. Yes.", 20, []string{"Pi is 3.14 exactly.", "Yes."}},
+		{"closing quote", `He said "Stop." Then left.`, 16, []string{`He said "Stop."`, "Then left."}},
+		{"paragraph", "Title\n\nBody text here.", 12, []string{"Title", "Body text", "here."}},
+		{"cjk without breaks", "一二三四五六七八九十", 4, []string{"一二三四", "五六七八", "九十"}},
+		{"cjk clause", "春眠不觉晓，处处闻啼鸟", 8, []string{"春眠不觉晓，", "处处闻啼鸟"}},
+		{"fits", "  One. Two.  ", 100, []string{"One. Two."}},
+		{"blank", " \n\n ", 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := splitLongText(tt.text, tt.size)
+			assert.Equal(t, tt.want, chunks)
+			for _, chunk := range chunks {
+				assert.LessOrEqual(t, utf8.RuneCountInString(chunk), tt.size)
+			}
+		})
+	}
+}
+
+// newLongFormServer returns a server which answers each synthesis with a WAV file whose samples are
+// the spoken text. Earlier chunks are answered later, to check that the order is kept.
+func newLongFormServer(t *testing.T, fail string) (*httptest.Server, *atomic.Int32) {
+	voiceText := regexp.MustCompile(`<voice name="[^"]*">(.*)</voice>`)
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tts/voices/list":
+			fmt.Fprintln(w, voiceListAPIGoodResponse)
+		case "/tts/v1":
+			calls.Add(1)
+			body, _ := io.ReadAll(r.Body)
+			text := voiceText.FindStringSubmatch(string(body))[1]
+			if text == fail {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			time.Sleep(time.Duration(20-len(text)) * time.Millisecond)
+			w.Write(makeWAV(testWAVFormat, []byte(text)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &calls
+}
+
+func TestSynthesizeLongForm(t *testing.T) {
+	ts, calls := newLongFormServer(t, "")
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, WithLazyInit(), WithTextToSpeechAPI(ts.URL+"/tts"))
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	text := "A. Bb. Ccc. Dddd. Eeeee. Ff."
+	audio, err := tts.SynthesizeLongFormWithContext(context.Background(), text, "ar-EG-Hoda", RIFF24khz16bitMonoPCM, WithChunkSize(6), WithChunkConcurrency(3))
+	require.NoError(t, err)
+	chunks := splitLongText(text, 6)
+	require.Len(t, chunks, 5)
+	assert.EqualValues(t, len(chunks), calls.Load())
+
+	format, data, err := parseWAV(audio)
+	require.NoError(t, err)
+	assert.Equal(t, testWAVFormat, format)
+	assert.Equal(t, strings.Join(chunks, ""), string(data))
+	assert.Zero(t, az.lifecycle.outstanding())
+}
+
+func TestSynthesizeLongFormErrors(t *testing.T) {
+	ts, _ := newLongFormServer(t, "Bb.")
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2,
+		WithLazyInit(), WithTextToSpeechAPI(ts.URL+"/tts"), WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	_, err = tts.SynthesizeLongFormWithContext(context.Background(), "A. Bb. Ccc.", "ar-EG-Hoda", RIFF24khz16bitMonoPCM, WithChunkSize(4))
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.ErrorContains(t, err, "chunk 2 of 3")
+
+	_, err = tts.SynthesizeLongFormWithContext(context.Background(), "A.", "ar-EG-Hoda", RAW24khz16bitMonoTruesilk)
+	assert.ErrorContains(t, err, "does not support")
+	_, err = tts.SynthesizeLongFormWithContext(context.Background(), "A.", "ar-EG-Hoda", RIFF24khz16bitMonoPCM, WithChunkSize(0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,333 @@
+package azure_cs_sdk
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultUserAgent is the User-Agent sent with every request unless overridden by WithUserAgent.
+const defaultUserAgent = "azuretts"
+
+// defaultSystemName and defaultSystemVersion identify the client in the websocket speech.config message.
+const defaultSystemName = "azure-cs-sdk"
+const defaultSystemVersion = "0.0.0"
+
+// ClientOption configures an AzureCS object at construction.
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	lazy                 bool
+	endpointResolver     EndpointResolver
+	textToSpeechAPI      string
+	textToSpeechWSAPI    string
+	speechToTextAPI      string
+	speechToTextWSAPI    string
+	tokenRefreshAPI      string
+	batchSynthesisAPI    string
+	tokenRefreshInterval time.Duration
+	tokenRefreshTimeout  time.Duration
+	secondaryKey         string
+	synthesizeTimeout    time.Duration
+	batchPollInterval    time.Duration
+	batchPollMaxInterval time.Duration
+	defaultVoice         string
+	defaultOutputFormat  AudioType
+	userAgent            string
+	systemName           string
+	systemVersion        string
+	logger               *slog.Logger
+	retryPolicy          RetryPolicy
+
+	rateLimit                 float64
+	rateLimitBurst            int
+	maxConcurrentRecognitions int
+
+	hedge *hedgePolicy
+
+	usageRecorder UsageRecorder
+
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	telemetry      *telemetry
+}
+
+// newClientOptions applies `opts` over the defaults for `region`. Endpoints come from the configured
+// EndpointResolver, RegionEndpoints(region) by default, and are then overridden by the individual
+// With*API options.
+func newClientOptions(region Region, opts []ClientOption) (clientOptions, error) {
+	o := clientOptions{
+		endpointResolver:     RegionEndpoints(region),
+		tokenRefreshInterval: tokenRefreshInterval,
+		tokenRefreshTimeout:  tokenRefreshTimeout,
+		synthesizeTimeout:    synthesizeActionTimeout,
+		batchPollInterval:    batchPollInterval,
+		batchPollMaxInterval: batchPollMaxInterval,
+		defaultOutputFormat:  RIFF24khz16bitMonoPCM,
+		userAgent:            defaultUserAgent,
+		systemName:           defaultSystemName,
+		systemVersion:        defaultSystemVersion,
+		logger:               slog.Default(),
+		retryPolicy:          DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	endpoints, err := o.endpointResolver.ResolveEndpoints()
+	if err != nil {
+		return o, fmt.Errorf("failed to resolve endpoints, %v", err)
+	}
+	if o.textToSpeechAPI == "" {
+		o.textToSpeechAPI = endpoints.TextToSpeech
+	}
+	if o.textToSpeechWSAPI == "" {
+		o.textToSpeechWSAPI = endpoints.TextToSpeechWS
+	}
+	if o.speechToTextAPI == "" {
+		o.speechToTextAPI = endpoints.SpeechToText
+	}
+	if o.speechToTextWSAPI == "" {
+		o.speechToTextWSAPI = endpoints.SpeechToTextWS
+	}
+	if o.tokenRefreshAPI == "" {
+		o.tokenRefreshAPI = endpoints.TokenRefresh
+	}
+	if o.batchSynthesisAPI == "" {
+		o.batchSynthesisAPI = endpoints.BatchSynthesis
+	}
+
+	if o.tracerProvider != nil || o.meterProvider != nil {
+		var attrs []attribute.KeyValue
+		if region != noRegion {
+			attrs = append(attrs, attrRegion.String(region.String()))
+		}
+		o.telemetry = newTelemetry(o.tracerProvider, o.meterProvider, attrs)
+	}
+	return o, nil
+}
+
+// synthesisURL returns the URL of the REST synthesis endpoint under the text-to-speech base URL.
+func (o *clientOptions) synthesisURL() string {
+	return strings.TrimSuffix(o.textToSpeechAPI, "/") + "/v1"
+}
+
+// voiceListURL returns the URL of the voice list under the text-to-speech base URL.
+func (o *clientOptions) voiceListURL() string {
+	return strings.TrimSuffix(o.textToSpeechAPI, "/") + "/voices/list"
+}
+
+// noRegion is passed as the Region of endpoint and container clients, which are not bound to one, so
+// that no region is reported in telemetry. Their endpoints always come from an EndpointResolver.
+const noRegion Region = -1
+
+// WithLazyInit defers every network call made during construction. The initial token is fetched on
+// first use and the voice list of AzureCSTTS on the first synthesis, or ahead of time through Warmup.
+// Failures then surface from the call that needed them rather than from New or NewTTS.
+func WithLazyInit() ClientOption {
+	return func(o *clientOptions) {
+		o.lazy = true
+	}
+}
+
+// WithEndpointResolver sets how the Speech service endpoints are resolved, e.g. CustomDomainEndpoints for
+// resources behind a custom subdomain or private endpoint. Defaults to RegionEndpoints for the client's Region.
+func WithEndpointResolver(resolver EndpointResolver) ClientOption {
+	return func(o *clientOptions) {
+		o.endpointResolver = resolver
+	}
+}
+
+// WithTextToSpeechAPI overrides the text-to-speech base URL, e.g. https://westus2.tts.speech.microsoft.com/cognitiveservices.
+// The synthesis (/v1) and voice list (/voices/list) paths are appended to it.
+func WithTextToSpeechAPI(baseURL string) ClientOption {
+	return func(o *clientOptions) {
+		o.textToSpeechAPI = baseURL
+	}
+}
+
+// WithTextToSpeechWSAPI overrides the URL of the text-to-speech websocket endpoint.
+func WithTextToSpeechWSAPI(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.textToSpeechWSAPI = url
+	}
+}
+
+// WithSpeechToTextAPI overrides the URL of the short audio speech-to-text REST endpoint.
+func WithSpeechToTextAPI(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.speechToTextAPI = url
+	}
+}
+
+// WithSpeechToTextWSAPI overrides the URL of the speech-to-text websocket endpoint.
+func WithSpeechToTextWSAPI(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.speechToTextWSAPI = url
+	}
+}
+
+// WithTokenRefreshAPI overrides the URL of the issueToken endpoint used by New and NewWithClient.
+func WithTokenRefreshAPI(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.tokenRefreshAPI = url
+	}
+}
+
+// WithBatchSynthesisAPI overrides the URL of the batch synthesis jobs, e.g. https://westus2.api.cognitive.microsoft.com/texttospeech/batchsyntheses.
+func WithBatchSynthesisAPI(url string) ClientOption {
+	return func(o *clientOptions) {
+		o.batchSynthesisAPI = url
+	}
+}
+
+// WithTokenRefreshInterval sets the interval between background token refreshes for providers which do not
+// report an expiry. Defaults to 9 minutes.
+func WithTokenRefreshInterval(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.tokenRefreshInterval = d
+	}
+}
+
+// WithTokenRefreshTimeout sets how long a single token refresh by New and NewWithClient may take. Defaults to 10 seconds.
+func WithTokenRefreshTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.tokenRefreshTimeout = d
+	}
+}
+
+// WithSecondaryKey sets the resource's other subscription key (KEY2 when the client was built with KEY1).
+// It is tried automatically when the issueToken endpoint rejects the primary key with 401 or 403, and is
+// used first from then on. It only applies to clients built from a subscription key.
+func WithSecondaryKey(key string) ClientOption {
+	return func(o *clientOptions) {
+		o.secondaryKey = key
+	}
+}
+
+// WithSynthesizeTimeout sets the timeout applied by AzureCSTTS.Synthesize. Defaults to 30 seconds.
+func WithSynthesizeTimeout(d time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.synthesizeTimeout = d
+	}
+}
+
+// WithBatchPollInterval sets how often BatchSynthesis.Wait polls a job: first after `initial`, then
+// doubling up to `maximum`. Defaults to 5 seconds and 1 minute.
+func WithBatchPollInterval(initial, maximum time.Duration) ClientOption {
+	return func(o *clientOptions) {
+		o.batchPollInterval = initial
+		o.batchPollMaxInterval = maximum
+	}
+}
+
+// WithDefaultVoice sets the voice used by SynthesizeWithContext when it is given an empty voice name.
+func WithDefaultVoice(name string) ClientOption {
+	return func(o *clientOptions) {
+		o.defaultVoice = name
+	}
+}
+
+// WithDefaultOutputFormat sets the format synthesized when DefaultAudioOutput is passed as the output
+// format, which is RIFF24khz16bitMonoPCM unless set. AzureCSTTS.DefaultOutputFormat returns it.
+func WithDefaultOutputFormat(format AudioType) ClientOption {
+	return func(o *clientOptions) {
+		o.defaultOutputFormat = format
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request. Defaults to "azuretts".
+func WithUserAgent(userAgent string) ClientOption {
+	return func(o *clientOptions) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithSystemInfo sets the application name and version reported in the websocket speech.config message.
+// Defaults to "azure-cs-sdk" and "0.0.0".
+func WithSystemInfo(name, version string) ClientOption {
+	return func(o *clientOptions) {
+		o.systemName = name
+		o.systemVersion = version
+	}
+}
+
+// WithLogger sets the logger used for diagnostics. Failed token refreshes are logged at warning level;
+// the token lifecycle, HTTP request and response summaries and websocket frames are logged at debug level.
+// Subscription keys and tokens are always redacted. Defaults to slog.Default(); nil disables logging.
+func WithLogger(logger *slog.Logger) ClientOption {
+	return func(o *clientOptions) {
+		if logger == nil {
+			logger = discardLogger
+		}
+		o.logger = logger
+	}
+}
+
+// WithRetryPolicy sets how transient HTTP failures are retried. Defaults to DefaultRetryPolicy;
+// pass NoRetryPolicy to send every request once.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(o *clientOptions) {
+		o.retryPolicy = policy
+	}
+}
+
+// WithRateLimit caps the HTTP requests for synthesis, the voice list and REST STT at `requestsPerSecond`,
+// allowing bursts of up to `burst` requests. Every attempt counts, including retries, so a throttled call
+// cannot retry faster than the limit. Calls over the limit wait for their turn, or until their context is done.
+func WithRateLimit(requestsPerSecond float64, burst int) ClientOption {
+	return func(o *clientOptions) {
+		o.rateLimit = requestsPerSecond
+		o.rateLimitBurst = burst
+	}
+}
+
+// WithMaxConcurrentRecognitions caps the number of open websocket recognitions. Further calls to
+// RecognizeWithContext wait for a running recognition to finish, or until their context is done.
+func WithMaxConcurrentRecognitions(n int) ClientOption {
+	return func(o *clientOptions) {
+		o.maxConcurrentRecognitions = n
+	}
+}
+
+// WithHedging sends a second synthesis request when no response to the first has arrived within `delay`,
+// and returns whichever audio arrives in full first, cancelling the other request. A response which is
+// still streaming its audio is not hedged. The hedge goes to `target`, e.g. a
+// client for another endpoint or region, or through the client itself when `target` is nil. Requests
+// which fail before the delay are not hedged. Use ContextWithHedgeStats to learn whether a hedge fired.
+func WithHedging(delay time.Duration, target *AzureCS) ClientOption {
+	return func(o *clientOptions) {
+		o.hedge = &hedgePolicy{delay: delay, target: target}
+	}
+}
+
+// WithUsageRecorder reports the Usage of every Synthesize* and Recognize* call to `recorder`, e.g. a
+// UsageAggregator. Use ContextWithUsageTag to attribute calls to a customer or feature.
+func WithUsageRecorder(recorder UsageRecorder) ClientOption {
+	return func(o *clientOptions) {
+		o.usageRecorder = recorder
+	}
+}
+
+// WithTracerProvider enables OpenTelemetry tracing. Spans are recorded for token refreshes, voice list
+// downloads, every synthesis, REST recognitions and websocket recognition sessions. Tracing is disabled
+// unless a provider is given.
+func WithTracerProvider(provider trace.TracerProvider) ClientOption {
+	return func(o *clientOptions) {
+		o.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider enables OpenTelemetry metrics: request latency, first hypothesis latency of websocket
+// recognitions, synthesized characters and seconds of audio recognized. Metrics are disabled unless a
+// provider is given.
+func WithMeterProvider(provider metric.MeterProvider) ClientOption {
+	return func(o *clientOptions) {
+		o.meterProvider = provider
+	}
+}
@@ -0,0 +1,64 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientOptionsDefaults(t *testing.T) {
+	o, err := newClientOptions(RegionWestUS2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://WestUS2.tts.speech.microsoft.com/cognitiveservices", o.textToSpeechAPI)
+	assert.Equal(t, "wss://WestUS2.stt.speech.microsoft.com/stt/speech/universal/v2", o.speechToTextWSAPI)
+	assert.Equal(t, "https://WestUS2.api.cognitive.microsoft.com/sts/v1.0/issueToken", o.tokenRefreshAPI)
+	assert.Equal(t, tokenRefreshInterval, o.tokenRefreshInterval)
+	assert.Equal(t, synthesizeActionTimeout, o.synthesizeTimeout)
+	assert.Equal(t, defaultUserAgent, o.userAgent)
+}
+
+func TestClientOptionsPointAtTestServer(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", func(r *http.Request) {
+		assert.Equal(t, "my-app/1.2", r.Header.Get("User-Agent"))
+	})
+	az := srv.newClient(t, "key",
+		WithSynthesizeTimeout(time.Second),
+		WithUserAgent("my-app/1.2"),
+		WithSystemInfo("my-app", "1.2"),
+	)
+
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	b, err := tts.Synthesize("hello", "ar-EG-Hoda", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+
+	assert.Contains(t, buildWSSpeechConfig(az.opts.systemName, az.opts.systemVersion), `"name":"my-app","version":"1.2"`)
+	require.NoError(t, az.Warmup(context.Background()))
+}
+
+func TestDefaultOutputFormatAppliesToDefaultAudioOutput(t *testing.T) {
+	var formats []string
+	srv := newTestSpeechServer(t, "token", func(r *http.Request) {
+		if r.URL.Path == "/tts/v1" {
+			formats = append(formats, r.Header.Get("X-Microsoft-OutputFormat"))
+		}
+	})
+	az := srv.newClient(t, "key", WithLazyInit(), WithDefaultOutputFormat(AUDIO24khz48kbitrateMonoMP3))
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	_, err = tts.SynthesizeWithContext(context.Background(), "hello", "ar-EG-Hoda", DefaultAudioOutput)
+	require.NoError(t, err)
+	stream, err := tts.SynthesizeRawSsmlStreamWithContext(context.Background(), "<speak/>", DefaultAudioOutput)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	_, err = tts.SynthesizeWithContext(context.Background(), "hello", "ar-EG-Hoda", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audio-24khz-48kbitrate-mono-mp3", "audio-24khz-48kbitrate-mono-mp3", "riff-16khz-16bit-mono-pcm"}, formats)
+	assert.Equal(t, "AudioType(-1)", DefaultAudioOutput.String())
+}
@@ -0,0 +1,318 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// BalancePolicy selects how a Pool spreads calls over its members.
+type BalancePolicy int
+
+const (
+	// LeastOutstanding sends a call to the member with the fewest calls in flight.
+	LeastOutstanding BalancePolicy = iota
+	// WeightedRoundRobin sends calls to the members in turn, in proportion to their weight.
+	WeightedRoundRobin
+)
+
+// PoolMember is one AzureCS object of a Pool, e.g. for another subscription key or region.
+type PoolMember struct {
+	Client *AzureCS
+	// Weight is the member's share of the calls under WeightedRoundRobin. Values below 1 are treated as 1.
+	Weight int
+}
+
+// PoolMemberHealth reports the state of one member of a Pool.
+type PoolMemberHealth struct {
+	// Healthy is false while the member is skipped by its circuit breaker.
+	Healthy bool
+	// ConsecutiveFailures counts the failed calls since the last successful one.
+	ConsecutiveFailures int
+	// OpenUntil is when a skipped member is probed again; zero while Healthy.
+	OpenUntil time.Time
+	// Outstanding is the number of calls in flight on the member, including those not made through the pool.
+	Outstanding int64
+}
+
+// poolMember is one member of a Pool.
+type poolMember struct {
+	member
+	weight  int
+	current int // smooth weighted round-robin state, guarded by Pool.mu
+}
+
+// Pool spreads calls over several AzureCS objects, to go beyond the concurrency of one subscription.
+//
+// Calls go to the healthy members whose client-side limits (WithRateLimit, WithMaxConcurrentRecognitions)
+// would admit them straight away, chosen by the BalancePolicy. When every healthy member is saturated, the
+// call waits on the limits of the member the policy chooses. A member is skipped for a while once its
+// circuit breaker opens; like FailoverClient, calls which fail because the service cannot be reached,
+// answers 5xx or throttles with 429 are retried on another member.
+//
+// The pool does not own its members: Shutdown and Close are conveniences which shut down all of them.
+type Pool struct {
+	policy  BalancePolicy
+	members []*poolMember
+
+	mu   sync.Mutex
+	next int // where LeastOutstanding starts looking, so that ties are spread.
+}
+
+// NewPool returns a Pool over `members`, whose circuit breakers follow `breaker`.
+func NewPool(members []PoolMember, policy BalancePolicy, breaker CircuitBreaker) (*Pool, error) {
+	if len(members) == 0 {
+		return nil, fmt.Errorf("at least one member is required")
+	}
+	if policy != LeastOutstanding && policy != WeightedRoundRobin {
+		return nil, fmt.Errorf("unknown balance policy %d", policy)
+	}
+	p := &Pool{policy: policy}
+	for i, m := range members {
+		if m.Client == nil {
+			return nil, fmt.Errorf("member %d has no client", i)
+		}
+		tts, err := m.Client.NewTTS()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the TTS client of member %d, %w", i, err)
+		}
+		stt, _ := m.Client.NewSTT()
+		p.members = append(p.members, &poolMember{
+			member: member{
+				client:  m.Client,
+				tts:     tts,
+				stt:     stt,
+				breaker: breakerState{breaker: breaker},
+				name:    fmt.Sprintf("member %d", i),
+				logAttr: slog.Int("member", i),
+			},
+			weight: max(m.Weight, 1),
+		})
+	}
+	return p, nil
+}
+
+// Health reports the state of every member, in the order they were given.
+func (p *Pool) Health() []PoolMemberHealth {
+	now := time.Now()
+	health := make([]PoolMemberHealth, 0, len(p.members))
+	for _, m := range p.members {
+		h := m.breaker.health(noRegion, now)
+		health = append(health, PoolMemberHealth{
+			Healthy:             h.Healthy,
+			ConsecutiveFailures: h.ConsecutiveFailures,
+			OpenUntil:           h.OpenUntil,
+			Outstanding:         m.client.lifecycle.outstanding(),
+		})
+	}
+	return health
+}
+
+// Shutdown shuts down every member; see AzureCS.Shutdown.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, m := range p.members {
+		errs = append(errs, m.client.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every member; see AzureCS.Close.
+func (p *Pool) Close() error {
+	for _, m := range p.members {
+		m.client.Close()
+	}
+	return nil
+}
+
+// pick chooses the member for the next attempt among those not `tried` yet: from the healthy members
+// with spare capacity if any, then the saturated healthy ones, then those whose breaker is open as a last
+// resort. It returns nil once every member has been tried.
+func (p *Pool) pick(tried map[*poolMember]bool, recognition bool) *poolMember {
+	now := time.Now()
+	var ready, saturated, open []*poolMember
+	for _, m := range p.members {
+		switch {
+		case tried[m]:
+		case !m.breaker.available(now):
+			open = append(open, m)
+		case m.client.limits.saturated(recognition):
+			saturated = append(saturated, m)
+		default:
+			ready = append(ready, m)
+		}
+	}
+	for _, tier := range [][]*poolMember{ready, saturated, open} {
+		if len(tier) > 0 {
+			return p.balance(tier)
+		}
+	}
+	return nil
+}
+
+// balance chooses one of `members` according to the policy.
+func (p *Pool) balance(members []*poolMember) *poolMember {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.policy == WeightedRoundRobin {
+		// smooth weighted round-robin, which interleaves the members rather than sending bursts to each.
+		total := 0
+		var best *poolMember
+		for _, m := range members {
+			m.current += m.weight
+			total += m.weight
+			if best == nil || m.current > best.current {
+				best = m
+			}
+		}
+		best.current -= total
+		return best
+	}
+
+	start := p.next % len(members)
+	p.next++
+	best := members[start]
+	for i := 1; i < len(members); i++ {
+		m := members[(start+i)%len(members)]
+		if m.client.lifecycle.outstanding() < best.client.lifecycle.outstanding() {
+			best = m
+		}
+	}
+	return best
+}
+
+// try runs `call` against one member after another, chosen by pick; see tryMembers.
+func (p *Pool) try(ctx context.Context, recognition bool, replayable bool, call func(m *poolMember) error) error {
+	tried := make(map[*poolMember]bool, len(p.members))
+	next := func() (*poolMember, bool) {
+		m := p.pick(tried, recognition)
+		tried[m] = true
+		return m, m != nil
+	}
+	_, err := tryMembers(ctx, "pool member", replayable, next, call)
+	return err
+}
+
+// NewTTS returns a TTS client which spreads syntheses over the members.
+func (p *Pool) NewTTS() *PoolTTS {
+	return &PoolTTS{pool: p}
+}
+
+// NewSTT returns a STT client which spreads recognitions over the members.
+func (p *Pool) NewSTT() *PoolSTT {
+	return &PoolSTT{pool: p}
+}
+
+// PoolTTS has the synthesis methods of AzureCSTTS and spreads them over the members of its Pool.
+type PoolTTS struct {
+	pool *Pool
+}
+
+// Warmup fetches the token and voice list of every member, and fails if any of them could not be warmed up.
+func (t *PoolTTS) Warmup(ctx context.Context) error {
+	var errs []error
+	for i, m := range t.pool.members {
+		if err := m.tts.Warmup(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("member %d: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Synthesize is AzureCSTTS.Synthesize over the pool. Each attempt is bounded by the WithSynthesizeTimeout
+// of its member.
+func (t *PoolTTS) Synthesize(speechText string, voiceName string, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := t.pool.try(context.Background(), false, true, func(m *poolMember) (err error) {
+		audio, err = m.tts.Synthesize(speechText, voiceName, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// SynthesizeWithContext is AzureCSTTS.SynthesizeWithContext over the pool.
+func (t *PoolTTS) SynthesizeWithContext(ctx context.Context, speechText string, voiceName string, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := t.pool.try(ctx, false, true, func(m *poolMember) (err error) {
+		audio, err = m.tts.SynthesizeWithContext(ctx, speechText, voiceName, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// SynthesizeSsmlWithContext is AzureCSTTS.SynthesizeSsmlWithContext over the pool.
+func (t *PoolTTS) SynthesizeSsmlWithContext(ctx context.Context, elems xml.Token, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := t.pool.try(ctx, false, true, func(m *poolMember) (err error) {
+		audio, err = m.tts.SynthesizeSsmlWithContext(ctx, elems, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// SynthesizeRawSsmlWithContext is AzureCSTTS.SynthesizeRawSsmlWithContext over the pool.
+func (t *PoolTTS) SynthesizeRawSsmlWithContext(ctx context.Context, ssml string, audioOutput AudioType) ([]byte, error) {
+	var audio []byte
+	err := t.pool.try(ctx, false, true, func(m *poolMember) (err error) {
+		audio, err = m.tts.SynthesizeRawSsmlWithContext(ctx, ssml, audioOutput)
+		return err
+	})
+	return audio, err
+}
+
+// PoolSTT has the recognition methods of AzureCSSTT and spreads them over the members of its Pool.
+type PoolSTT struct {
+	pool *Pool
+}
+
+// RecognizeShortSimple is AzureCSSTT.RecognizeShortSimple over the pool.
+func (s *PoolSTT) RecognizeShortSimple(reader io.Reader, audioType AudioType, language string, opts ...Option) (*RecognizeSimpleResponse, error) {
+	return s.RecognizeShortSimpleWithContext(context.Background(), reader, audioType, language, opts...)
+}
+
+// RecognizeShortSimpleWithContext is AzureCSSTT.RecognizeShortSimpleWithContext over the pool. As with
+// FailoverSTT, the audio is only sent to another member after a failure when `reader` implements both
+// io.ReaderAt and io.Seeker.
+func (s *PoolSTT) RecognizeShortSimpleWithContext(
+	ctx context.Context,
+	reader io.Reader,
+	audioType AudioType,
+	language string,
+	opts ...Option,
+) (*RecognizeSimpleResponse, error) {
+	replay, replayable := newReplayableReader(reader)
+	var resp *RecognizeSimpleResponse
+	err := s.pool.try(ctx, false, replayable, func(m *poolMember) (err error) {
+		resp, err = m.stt.RecognizeShortSimpleWithContext(ctx, replay(), audioType, language, opts...)
+		return err
+	})
+	return resp, err
+}
+
+// Recognize is AzureCSSTT.Recognize over the pool.
+func (s *PoolSTT) Recognize(reader io.Reader, audioType AudioType, languages []string, opts ...Option) (<-chan RecognizeEvent, error) {
+	return s.RecognizeWithContext(context.Background(), reader, audioType, languages, opts...)
+}
+
+// RecognizeWithContext is AzureCSSTT.RecognizeWithContext over the pool. The session counts as
+// outstanding on its member until its events channel is closed.
+func (s *PoolSTT) RecognizeWithContext(
+	ctx context.Context,
+	reader io.Reader,
+	audioType AudioType,
+	languages []string,
+	opts ...Option,
+) (<-chan RecognizeEvent, error) {
+	var events <-chan RecognizeEvent
+	err := s.pool.try(ctx, true, true, func(m *poolMember) (err error) {
+		events, err = m.stt.RecognizeWithContext(ctx, reader, audioType, languages, opts...)
+		return err
+	})
+	return events, err
+}
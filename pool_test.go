@@ -0,0 +1,145 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolWeightedRoundRobin(t *testing.T) {
+	heavy := newTestSpeechServer(t, "token", nil)
+	light := newTestSpeechServer(t, "token", nil)
+	p, err := NewPool([]PoolMember{
+		{Client: heavy.newClient(t, "key1", WithLazyInit()), Weight: 2},
+		{Client: light.newClient(t, "key2", WithLazyInit())},
+	}, WeightedRoundRobin, DefaultCircuitBreaker())
+	require.NoError(t, err)
+	tts := p.NewTTS()
+
+	for i := 0; i < 6; i++ {
+		_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 4, heavy.synthesizeCalls.Load())
+	assert.EqualValues(t, 2, light.synthesizeCalls.Load())
+}
+
+func TestPoolLeastOutstanding(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	busy, busyTTS := newBlockingTTS(t, started, release)
+	idle := newTestSpeechServer(t, "token", nil)
+
+	// a call made on the member directly counts as outstanding too.
+	result := make(chan error, 1)
+	go func() {
+		_, err := busyTTS.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+		result <- err
+	}()
+	<-started
+	defer func() {
+		close(release)
+		require.NoError(t, <-result)
+	}()
+
+	p, err := NewPool([]PoolMember{
+		{Client: busy},
+		{Client: idle.newClient(t, "key", WithLazyInit())},
+	}, LeastOutstanding, DefaultCircuitBreaker())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, p.Health()[0].Outstanding)
+
+	tts := p.NewTTS()
+	for i := 0; i < 3; i++ {
+		_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 3, idle.synthesizeCalls.Load())
+}
+
+func TestPoolAvoidsSaturatedMembers(t *testing.T) {
+	limited := newTestSpeechServer(t, "token", nil)
+	free := newTestSpeechServer(t, "token", nil)
+	p, err := NewPool([]PoolMember{
+		{Client: limited.newClient(t, "key1", WithLazyInit(), WithRateLimit(0.001, 1))},
+		{Client: free.newClient(t, "key2", WithLazyInit())},
+	}, LeastOutstanding, DefaultCircuitBreaker())
+	require.NoError(t, err)
+	tts := p.NewTTS()
+
+	// the first call spends the only token of the limited member, which is then left alone.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		_, err := tts.SynthesizeRawSsmlWithContext(ctx, "<speak/>", RIFF16khz16bitMonoPCM)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, limited.synthesizeCalls.Load())
+	assert.EqualValues(t, 2, free.synthesizeCalls.Load())
+}
+
+func TestPoolExcludesUnhealthyMembers(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := newTestSpeechServer(t, "token", nil)
+
+	downClient, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionEastUS,
+		WithLazyInit(), WithTextToSpeechAPI(down.URL+"/tts"), WithRetryPolicy(NoRetryPolicy()))
+	require.NoError(t, err)
+	p, err := NewPool([]PoolMember{
+		{Client: downClient},
+		{Client: up.newClient(t, "key", WithLazyInit())},
+	}, WeightedRoundRobin, CircuitBreaker{FailureThreshold: 1, Cooldown: time.Minute})
+	require.NoError(t, err)
+	defer p.Close()
+	tts := p.NewTTS()
+
+	for i := 0; i < 4; i++ {
+		_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 4, up.synthesizeCalls.Load())
+
+	health := p.Health()
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, 1, health[0].ConsecutiveFailures)
+	assert.True(t, health[1].Healthy)
+}
+
+func TestPoolSynthesizeAppliesMemberTimeout(t *testing.T) {
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tts/voices/list" {
+			w.Write([]byte(voiceListAPIGoodResponse))
+			return
+		}
+		// reading the body lets the server notice when the client goes away.
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer hanging.Close()
+	up := newTestSpeechServer(t, "token", nil)
+
+	hangingClient, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionEastUS,
+		WithLazyInit(), WithTextToSpeechAPI(hanging.URL+"/tts"), WithSynthesizeTimeout(50*time.Millisecond), WithRetryPolicy(NoRetryPolicy()))
+	require.NoError(t, err)
+	p, err := NewPool([]PoolMember{
+		{Client: hangingClient},
+		{Client: up.newClient(t, "key", WithLazyInit())},
+	}, LeastOutstanding, DefaultCircuitBreaker())
+	require.NoError(t, err)
+	defer p.Close()
+
+	audio, err := p.NewTTS().Synthesize("hello", "zh-CN-XiaoxiaoNeural", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(audio))
+	assert.EqualValues(t, 1, up.synthesizeCalls.Load())
+	assert.Equal(t, 1, p.Health()[0].ConsecutiveFailures)
+}
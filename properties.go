@@ -1,5 +1,10 @@
 package azure_cs_sdk
 
+import (
+	"fmt"
+	"strings"
+)
+
 // AudioOutput types represent the supported audio encoding formats for the text-to-speech endpoint.
 // This type is required when requesting to azuretexttospeech.Synthesize text-to-speed request.
 // Each incorporates a bitrate and encoding type. The Speech service supports 24 kHz, 16 kHz, and 8 kHz audio outputs.
@@ -91,34 +96,55 @@ var MapAudioFileExtensions = map[string]string{
 // 	"OGG24khz16bitMonoOpus":        OGG24khz16bitMonoOpus,
 // }
 
+// DefaultAudioOutput stands for the format set by WithDefaultOutputFormat, RIFF24khz16bitMonoPCM by default.
+// It may be passed wherever an AudioType selects the output format of a synthesis.
+const DefaultAudioOutput AudioType = -1
+
 func (a AudioType) String() string {
-	return []string{
-		"raw-16khz-16bit-mono-pcm",
-		"raw-24khz-16bit-mono-pcm",
-		"raw-48khz-16bit-mono-pcm",
-		"raw-8khz-8bit-mono-mulaw",
-		"raw-8khz-8bit-mono-alaw",
-		"audio-16khz-32kbitrate-mono-mp3",
-		"audio-16khz-128kbitrate-mono-mp3",
-		"audio-24khz-96kbitrate-mono-mp3",
-		"audio-48khz-96kbitrate-mono-mp3",
-		"raw-16khz-16bit-mono-truesilk",
-		"webm-16khz-16bit-mono-opus",
-		"ogg-16khz-16bit-mono-opus",
-		"ogg-48khz-16bit-mono-opus",
-		"riff-16khz-16bit-mono-pcm",
-		"riff-24khz-16bit-mono-pcm",
-		"riff-48khz-16bit-mono-pcm",
-		"riff-8khz-8bit-mono-mulaw",
-		"riff-8khz-8bit-mono-alaw",
-		"audio-16khz-64kbitrate-mono-mp3",
-		"audio-24khz-48kbitrate-mono-mp3",
-		"audio-24khz-160kbitrate-mono-mp3",
-		"audio-48khz-192kbitrate-mono-mp3",
-		"raw-24khz-16bit-mono-truesilk",
-		"webm-24khz-16bit-mono-opus",
-		"ogg-24khz-16bit-mono-opus",
-	}[a]
+	if a < 0 || int(a) >= len(audioTypeNames) {
+		return fmt.Sprintf("AudioType(%d)", int(a))
+	}
+	return audioTypeNames[a]
+}
+
+// AudioTypeString returns the AudioType named `s`, e.g. riff-24khz-16bit-mono-pcm as sent in the
+// X-Microsoft-OutputFormat header. The match is case-insensitive.
+func AudioTypeString(s string) (AudioType, error) {
+	for i, name := range audioTypeNames {
+		if strings.EqualFold(name, s) {
+			return AudioType(i), nil
+		}
+	}
+	return 0, fmt.Errorf("%s does not belong to AudioType values", s)
+}
+
+// audioTypeNames are the X-Microsoft-OutputFormat names of the AudioType values, in order.
+var audioTypeNames = []string{
+	"raw-16khz-16bit-mono-pcm",
+	"raw-24khz-16bit-mono-pcm",
+	"raw-48khz-16bit-mono-pcm",
+	"raw-8khz-8bit-mono-mulaw",
+	"raw-8khz-8bit-mono-alaw",
+	"audio-16khz-32kbitrate-mono-mp3",
+	"audio-16khz-128kbitrate-mono-mp3",
+	"audio-24khz-96kbitrate-mono-mp3",
+	"audio-48khz-96kbitrate-mono-mp3",
+	"raw-16khz-16bit-mono-truesilk",
+	"webm-16khz-16bit-mono-opus",
+	"ogg-16khz-16bit-mono-opus",
+	"ogg-48khz-16bit-mono-opus",
+	"riff-16khz-16bit-mono-pcm",
+	"riff-24khz-16bit-mono-pcm",
+	"riff-48khz-16bit-mono-pcm",
+	"riff-8khz-8bit-mono-mulaw",
+	"riff-8khz-8bit-mono-alaw",
+	"audio-16khz-64kbitrate-mono-mp3",
+	"audio-24khz-48kbitrate-mono-mp3",
+	"audio-24khz-160kbitrate-mono-mp3",
+	"audio-48khz-192kbitrate-mono-mp3",
+	"raw-24khz-16bit-mono-truesilk",
+	"webm-24khz-16bit-mono-opus",
+	"ogg-24khz-16bit-mono-opus",
 }
 
 // Gender type for the digitized language
@@ -183,4 +209,22 @@ const (
 	RegionWestUS
 	RegionWestUS2
 	RegionWestUS3
+	// Azure Government, served from the CloudUSGov host suffixes.
+	RegionUSGovArizona
+	RegionUSGovVirginia
+	// Azure China, served from the CloudChina host suffixes.
+	RegionChinaEast2
+	RegionChinaNorth2
+	RegionChinaNorth3
 )
+
+// Cloud returns the Azure cloud the region belongs to.
+func (r Region) Cloud() Cloud {
+	switch r {
+	case RegionUSGovArizona, RegionUSGovVirginia:
+		return CloudUSGov
+	case RegionChinaEast2, RegionChinaNorth2, RegionChinaNorth3:
+		return CloudChina
+	}
+	return CloudPublic
+}
@@ -8,11 +8,11 @@ import (
 	"strings"
 )
 
-const _RegionName = "SouthAfricaNorthEastAsiaSoutheastAsiaAustraliaEastCentralIndiaJapanEastJapanWestKoreaCentralCanadaCentralCanadaEastNorthEuropeWestEuropeFranceCentralGermanyWestCentralItalyNorthNorwayEastSwedenCentralSwitzerlandNorthSwitzerlandWestUKSouthUKWestUAENorthBrazilSouthQatarCentralCentralUSEastUSEastUS2NorthCentralUSSouthCentralUSWestCentralUSWestUSWestUS2WestUS3"
+const _RegionName = "SouthAfricaNorthEastAsiaSoutheastAsiaAustraliaEastCentralIndiaJapanEastJapanWestKoreaCentralCanadaCentralCanadaEastNorthEuropeWestEuropeFranceCentralGermanyWestCentralItalyNorthNorwayEastSwedenCentralSwitzerlandNorthSwitzerlandWestUKSouthUKWestUAENorthBrazilSouthQatarCentralCentralUSEastUSEastUS2NorthCentralUSSouthCentralUSWestCentralUSWestUSWestUS2WestUS3USGovArizonaUSGovVirginiaChinaEast2ChinaNorth2ChinaNorth3"
 
-var _RegionIndex = [...]uint16{0, 16, 24, 37, 50, 62, 71, 80, 92, 105, 115, 126, 136, 149, 167, 177, 187, 200, 216, 231, 238, 244, 252, 263, 275, 284, 290, 297, 311, 325, 338, 344, 351, 358}
+var _RegionIndex = [...]uint16{0, 16, 24, 37, 50, 62, 71, 80, 92, 105, 115, 126, 136, 149, 167, 177, 187, 200, 216, 231, 238, 244, 252, 263, 275, 284, 290, 297, 311, 325, 338, 344, 351, 358, 370, 383, 393, 404, 415}
 
-const _RegionLowerName = "southafricanortheastasiasoutheastasiaaustraliaeastcentralindiajapaneastjapanwestkoreacentralcanadacentralcanadaeastnortheuropewesteuropefrancecentralgermanywestcentralitalynorthnorwayeastswedencentralswitzerlandnorthswitzerlandwestuksouthukwestuaenorthbrazilsouthqatarcentralcentraluseastuseastus2northcentralussouthcentraluswestcentraluswestuswestus2westus3"
+const _RegionLowerName = "southafricanortheastasiasoutheastasiaaustraliaeastcentralindiajapaneastjapanwestkoreacentralcanadacentralcanadaeastnortheuropewesteuropefrancecentralgermanywestcentralitalynorthnorwayeastswedencentralswitzerlandnorthswitzerlandwestuksouthukwestuaenorthbrazilsouthqatarcentralcentraluseastuseastus2northcentralussouthcentraluswestcentraluswestuswestus2westus3usgovarizonausgovvirginiachinaeast2chinanorth2chinanorth3"
 
 func (i Region) String() string {
 	if i < 0 || i >= Region(len(_RegionIndex)-1) {
@@ -58,9 +58,14 @@ func _RegionNoOp() {
 	_ = x[RegionWestUS-(30)]
 	_ = x[RegionWestUS2-(31)]
 	_ = x[RegionWestUS3-(32)]
+	_ = x[RegionUSGovArizona-(33)]
+	_ = x[RegionUSGovVirginia-(34)]
+	_ = x[RegionChinaEast2-(35)]
+	_ = x[RegionChinaNorth2-(36)]
+	_ = x[RegionChinaNorth3-(37)]
 }
 
-var _RegionValues = []Region{RegionSouthAfricaNorth, RegionEastAsia, RegionSoutheastAsia, RegionAustraliaEast, RegionCentralIndia, RegionJapanEast, RegionJapanWest, RegionKoreaCentral, RegionCanadaCentral, RegionCanadaEast, RegionNorthEurope, RegionWestEurope, RegionFranceCentral, RegionGermanyWestCentral, RegionItalyNorth, RegionNorwayEast, RegionSwedenCentral, RegionSwitzerlandNorth, RegionSwitzerlandWest, RegionUKSouth, RegionUKWest, RegionUAENorth, RegionBrazilSouth, RegionQatarCentral, RegionCentralUS, RegionEastUS, RegionEastUS2, RegionNorthCentralUS, RegionSouthCentralUS, RegionWestCentralUS, RegionWestUS, RegionWestUS2, RegionWestUS3}
+var _RegionValues = []Region{RegionSouthAfricaNorth, RegionEastAsia, RegionSoutheastAsia, RegionAustraliaEast, RegionCentralIndia, RegionJapanEast, RegionJapanWest, RegionKoreaCentral, RegionCanadaCentral, RegionCanadaEast, RegionNorthEurope, RegionWestEurope, RegionFranceCentral, RegionGermanyWestCentral, RegionItalyNorth, RegionNorwayEast, RegionSwedenCentral, RegionSwitzerlandNorth, RegionSwitzerlandWest, RegionUKSouth, RegionUKWest, RegionUAENorth, RegionBrazilSouth, RegionQatarCentral, RegionCentralUS, RegionEastUS, RegionEastUS2, RegionNorthCentralUS, RegionSouthCentralUS, RegionWestCentralUS, RegionWestUS, RegionWestUS2, RegionWestUS3, RegionUSGovArizona, RegionUSGovVirginia, RegionChinaEast2, RegionChinaNorth2, RegionChinaNorth3}
 
 var _RegionNameToValueMap = map[string]Region{
 	_RegionName[0:16]:         RegionSouthAfricaNorth,
@@ -129,6 +134,16 @@ var _RegionNameToValueMap = map[string]Region{
 	_RegionLowerName[344:351]: RegionWestUS2,
 	_RegionName[351:358]:      RegionWestUS3,
 	_RegionLowerName[351:358]: RegionWestUS3,
+	_RegionName[358:370]:      RegionUSGovArizona,
+	_RegionLowerName[358:370]: RegionUSGovArizona,
+	_RegionName[370:383]:      RegionUSGovVirginia,
+	_RegionLowerName[370:383]: RegionUSGovVirginia,
+	_RegionName[383:393]:      RegionChinaEast2,
+	_RegionLowerName[383:393]: RegionChinaEast2,
+	_RegionName[393:404]:      RegionChinaNorth2,
+	_RegionLowerName[393:404]: RegionChinaNorth2,
+	_RegionName[404:415]:      RegionChinaNorth3,
+	_RegionLowerName[404:415]: RegionChinaNorth3,
 }
 
 var _RegionNames = []string{
@@ -165,6 +180,11 @@ var _RegionNames = []string{
 	_RegionName[338:344],
 	_RegionName[344:351],
 	_RegionName[351:358],
+	_RegionName[358:370],
+	_RegionName[370:383],
+	_RegionName[383:393],
+	_RegionName[393:404],
+	_RegionName[404:415],
 }
 
 // RegionString retrieves an enum value from the enum constants string name.
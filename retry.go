@@ -0,0 +1,264 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how HTTP requests which fail with a transient error are retried. It applies to
+// synthesis, the voice list, token refresh, short audio recognition and the websocket handshake.
+//
+// A request is only retried when its body can be replayed. Requests built from a *bytes.Reader,
+// *bytes.Buffer, *strings.Reader or an io.Seeker are replayable; other streamed readers are sent once.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first. Values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles with every further retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the computed delay between attempts. When the service asks through Retry-After to
+	// wait longer than MaxBackoff, the request is not retried and the response is returned as is.
+	MaxBackoff time.Duration
+	// Jitter is the fraction of each delay, between 0 and 1, which is randomized.
+	Jitter float64
+	// RetryableStatusCodes lists the HTTP status codes which are retried. Network errors are always retried.
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy returns the policy used unless WithRetryPolicy is given: 3 attempts, starting at
+// 500ms and capped at 10s, retrying 408, 429, 500, 502, 503 and 504.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond * 500,
+		MaxBackoff:     time.Second * 10,
+		Jitter:         0.5,
+		RetryableStatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// NoRetryPolicy returns a policy which sends every request exactly once.
+func NoRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// isRetryableStatus reports whether `statusCode` is listed in RetryableStatusCodes.
+func (p RetryPolicy) isRetryableStatus(statusCode int) bool {
+	for _, code := range p.RetryableStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before attempt `attempt`+1. A Retry-After header on `res` takes precedence
+// over the computed delay, which is capped by MaxBackoff. It reports false when Retry-After asks for
+// longer than MaxBackoff, in which case the request must not be retried.
+func (p RetryPolicy) backoff(attempt int, res *http.Response) (time.Duration, bool) {
+	if res != nil {
+		if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxBackoff > 0 && retryAfter > p.MaxBackoff {
+				return 0, false
+			}
+			return retryAfter, true
+		}
+	}
+
+	backoff := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || backoff < p.MaxBackoff); i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 && backoff > 0 {
+		spread := time.Duration(float64(backoff) * jitter)
+		backoff = backoff - spread + time.Duration(rand.Int63n(int64(spread)+1))
+	}
+	return backoff, true
+}
+
+// wait sleeps for the delay before attempt `attempt`+1. It returns false, without sleeping, when `ctx`
+// would expire first or the service asked for a longer delay than MaxBackoff, in which case the caller
+// gives up with the last result.
+func (p RetryPolicy) wait(ctx context.Context, attempt int, res *http.Response) bool {
+	delay, ok := p.backoff(attempt, res)
+	if !ok {
+		return false
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// do sends `req` through `send`, retrying transient failures according to the policy.
+func (p RetryPolicy) do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	attempts := p.MaxAttempts
+	if !isReplayable(req) {
+		attempts = 1
+	}
+
+	ctx := req.Context()
+	current := req
+	for attempt := 1; ; attempt++ {
+		res, err := send(current)
+		if attempt >= attempts || ctx.Err() != nil {
+			return res, err
+		}
+		if err == nil && !p.isRetryableStatus(res.StatusCode) {
+			return res, nil
+		}
+		if err != nil && !isTransportError(err) {
+			return res, err
+		}
+		if !p.wait(ctx, attempt, res) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, 4096))
+			res.Body.Close()
+		}
+
+		if current, err = rewindRequest(req); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isTransportError reports whether `err` was returned by the http.Client while sending the request, as
+// opposed to e.g. a failed authorization which retrying cannot fix.
+func isTransportError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// isReplayable reports whether the body of `req` can be sent again.
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// rewindRequest returns a copy of `req` with a fresh body for another attempt.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// setSeekableBody makes a request replayable when its body is an io.Seeker, rewinding to the position
+// the reader had when the request was built.
+//
+// The transport may keep reading a request body after RoundTrip has returned, and only closes it once
+// it is done. The reader is therefore only rewound after the previous attempt's body was closed, and
+// a closed body refuses further reads.
+func setSeekableBody(req *http.Request, reader io.Reader) {
+	if req.GetBody != nil {
+		return
+	}
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		return
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		// not actually seekable, e.g. a pipe behind an *os.File.
+		return
+	}
+
+	var mu sync.Mutex
+	current := newSeekableBody(seeker)
+	req.Body = current
+	req.GetBody = func() (io.ReadCloser, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case <-current.released:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		current = newSeekableBody(seeker)
+		return current, nil
+	}
+}
+
+// seekableBody is one attempt's view of a shared io.ReadSeeker. `released` is closed once the body was
+// closed and no read is in progress.
+type seekableBody struct {
+	mu       sync.Mutex
+	reader   io.Reader
+	closed   bool
+	released chan struct{}
+}
+
+func newSeekableBody(reader io.Reader) *seekableBody {
+	return &seekableBody{reader: reader, released: make(chan struct{})}
+}
+
+func (b *seekableBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, errors.New("read on closed request body")
+	}
+	return b.reader.Read(p)
+}
+
+func (b *seekableBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		close(b.released)
+	}
+	return nil
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
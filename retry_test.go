@@ -0,0 +1,191 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.InitialBackoff = time.Millisecond
+	p.MaxBackoff = time.Millisecond * 10
+	return p
+}
+
+func TestRetryPolicyRetriesTransientStatus(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "<speak/>", string(body), "every attempt must carry the full body")
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("audio"))
+	}))
+	defer ts.Close()
+
+	tts := &AzureCSTTS{
+		textToSpeechURL: ts.URL,
+		client: &AzureCS{
+			tokenProvider: NewStaticTokenProvider("token"),
+			httpClient:    http.DefaultClient,
+			opts:          clientOptions{retryPolicy: testRetryPolicy()},
+		},
+	}
+	b, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicyReplaysSeekableSTTBody(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "audio", string(body))
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"RecognitionStatus":"Success","DisplayText":"hello"}`))
+	}))
+	defer ts.Close()
+
+	stt := &AzureCSSTT{
+		speechToTextAPI: ts.URL,
+		client: &AzureCS{
+			tokenProvider: NewStaticTokenProvider("token"),
+			httpClient:    http.DefaultClient,
+			opts:          clientOptions{retryPolicy: testRetryPolicy()},
+		},
+	}
+	resp, err := stt.RecognizeShortSimple(bytes.NewReader([]byte("audio")), RIFF16khz16bitMonoPCM, "en-US")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", resp.DisplayText)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicySkipsStreamedBody(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	stt := &AzureCSSTT{
+		speechToTextAPI: ts.URL,
+		client: &AzureCS{
+			tokenProvider: NewStaticTokenProvider("token"),
+			httpClient:    http.DefaultClient,
+			opts:          clientOptions{retryPolicy: testRetryPolicy()},
+		},
+	}
+	// io.MultiReader hides the underlying seeker, so the body cannot be replayed.
+	_, err := stt.RecognizeShortSimple(io.MultiReader(strings.NewReader("audio")), RIFF16khz16bitMonoPCM, "en-US")
+	require.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Second * 5}
+	backoff := func(attempt int, res *http.Response) time.Duration {
+		d, ok := p.backoff(attempt, res)
+		require.True(t, ok)
+		return d
+	}
+	assert.Equal(t, time.Second, backoff(1, nil))
+	assert.Equal(t, time.Second*4, backoff(3, nil))
+	assert.Equal(t, time.Second*5, backoff(10, nil))
+
+	res := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	assert.Equal(t, time.Second*3, backoff(1, res))
+
+	// a Retry-After beyond MaxBackoff is honoured by not retrying at all.
+	res.Header.Set("Retry-After", "120")
+	_, ok := p.backoff(1, res)
+	assert.False(t, ok)
+}
+
+func TestRetryPolicyGivesUpOnLongRetryAfter(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer ts.Close()
+
+	tts := &AzureCSTTS{
+		textToSpeechURL: ts.URL,
+		client: &AzureCS{
+			tokenProvider: NewStaticTokenProvider("token"),
+			httpClient:    http.DefaultClient,
+			opts:          clientOptions{retryPolicy: testRetryPolicy()},
+		},
+	}
+	_, err := tts.SynthesizeRawSsmlWithContext(context.Background(), "<speak/>", RIFF16khz16bitMonoPCM)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, time.Minute, apiErr.RetryAfter)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestSeekableBodyWaitsForRelease(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	require.NoError(t, err)
+	reader := &seekOnlyReader{Reader: strings.NewReader("audio")}
+	setSeekableBody(req, reader)
+	first := req.Body
+
+	got := make(chan io.ReadCloser, 1)
+	go func() {
+		body, err := req.GetBody()
+		assert.NoError(t, err)
+		got <- body
+	}()
+	select {
+	case <-got:
+		t.Fatal("GetBody rewound the reader while the previous body was still open")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	require.NoError(t, first.Close())
+	_, err = first.Read(make([]byte, 1))
+	assert.Error(t, err, "a closed body must not read from the shared reader")
+	second := <-got
+	b, err := io.ReadAll(second)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+}
+
+// seekOnlyReader hides the concrete type of a *strings.Reader so that http.NewRequest does not set GetBody.
+type seekOnlyReader struct {
+	*strings.Reader
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	d, ok := parseRetryAfter("7", now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Second*7, d)
+
+	d, ok = parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Minute, d)
+
+	_, ok = parseRetryAfter("soon", now)
+	assert.False(t, ok)
+}
@@ -93,3 +93,24 @@ type Emphasis struct {
 	Level   EmphasisLevel `xml:"level,attr,omitempty"`
 	Child   xml.Token     `xml:",innerxml"`
 }
+
+type VisemeType string
+
+const (
+	// Request the facial positions of a 2D cartoon face, sent as SVG animations.
+	VisemeTypeRedlipsFront VisemeType = "redlips_front"
+	// Request 55 facial blendshape values per frame for driving a 3D face.
+	VisemeTypeFacialExpression VisemeType = "FacialExpression"
+)
+
+// Viseme requests animation data along with the viseme IDs. It goes inside a Voice, before the text.
+type Viseme struct {
+	XMLName xml.Name   `xml:"mstts:viseme"`
+	Type    VisemeType `xml:"type,attr"`
+}
+
+func NewViseme(visemeType VisemeType) Viseme {
+	return Viseme{
+		Type: visemeType,
+	}
+}
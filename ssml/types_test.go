@@ -39,3 +39,13 @@ func Test_multipleExpressAs(t *testing.T) {
 	}
 	assert.Equal(t, `<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xmlns:mstts="http://www.w3.org/2001/mstts" xml:lang="en-US"><voice name="en-US-JennyNeural"><mstts:express-as style="normal">hello</mstts:express-as><mstts:express-as style="normal">world</mstts:express-as></voice></speak>`, string(b))
 }
+
+func Test_viseme(t *testing.T) {
+	voice := ssml.NewVoice("en-US-JennyNeural")
+	voice.Child = []xml.Token{ssml.NewViseme(ssml.VisemeTypeFacialExpression)}
+	b, err := xml.Marshal(voice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `<voice name="en-US-JennyNeural"><mstts:viseme type="FacialExpression"></mstts:viseme></voice>`, string(b))
+}
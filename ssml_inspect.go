@@ -0,0 +1,51 @@
+package azure_cs_sdk
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// ssmlSummary describes an SSML document for telemetry and accounting.
+type ssmlSummary struct {
+	voices []string // voice names in document order, without duplicates.
+	text   string   // spoken text with the markup removed and runs of whitespace collapsed to one space.
+}
+
+// inspectSSML extracts the voices and the spoken text of an SSML document. Malformed documents yield
+// whatever was decoded before the error.
+func inspectSSML(doc string) ssmlSummary {
+	var summary ssmlSummary
+	var text strings.Builder
+
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "voice" {
+				continue
+			}
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "name" && !containsString(summary.voices, attr.Value) {
+					summary.voices = append(summary.voices, attr.Value)
+				}
+			}
+		case xml.CharData:
+			text.Write(t)
+		}
+	}
+	summary.text = strings.Join(strings.Fields(text.String()), " ")
+	return summary
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,614 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// canStitchAudio reports whether stitchAudio can join files of the given format.
+func canStitchAudio(audioType AudioType) bool {
+	switch audioType {
+	case RAW16khz16bitMonoTruesilk, RAW24khz16bitMonoTruesilk:
+		return false
+	}
+	return true
+}
+
+// stitchAudio joins the audio files in `parts`, in order, into one file of the given format. Raw PCM,
+// mu-law, a-law and MP3 streams are concatenated; WAV, Ogg and WebM containers are merged into a
+// single container.
+func stitchAudio(audioType AudioType, parts [][]byte) ([]byte, error) {
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	switch audioType {
+	case RIFF8khz8bitMonoAlaw, RIFF8khz8bitMonoMulaw, RIFF16khz16bitMonoPCM, RIFF24khz16bitMonoPCM, RIFF48khz16bitMonoPCM:
+		return stitchWAV(parts)
+	case OGG16khz16bitMonoOpus, OGG24khz16bitMonoOpus, OGG48khz16bitMonoOpus:
+		return stitchOgg(parts)
+	case WEBM16khz16bitMonoOpus, WEBM24khz16bitMonoOpus:
+		return stitchWebM(parts)
+	case RAW16khz16bitMonoTruesilk, RAW24khz16bitMonoTruesilk:
+		return nil, fmt.Errorf("cannot stitch %s audio", audioType)
+	}
+	return bytes.Join(parts, nil), nil
+}
+
+// stitchWAV concatenates the samples of WAV files of the same format behind a single RIFF header.
+// Chunks other than fmt and data are dropped.
+func stitchWAV(parts [][]byte) ([]byte, error) {
+	var format []byte
+	var data []byte
+	for i, part := range parts {
+		partFormat, partData, err := parseWAV(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse WAV audio %d, %w", i+1, err)
+		}
+		if format == nil {
+			format = partFormat
+		} else if !bytes.Equal(format, partFormat) {
+			return nil, fmt.Errorf("WAV audio %d has a different format from the first", i+1)
+		}
+		data = append(data, partData...)
+	}
+
+	out := make([]byte, 0, 12+8+len(format)+8+len(data)+1)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	out = append(out, "WAVE"...)
+	out = appendRIFFChunk(out, "fmt ", format)
+	out = appendRIFFChunk(out, "data", data)
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, nil
+}
+
+// parseWAV returns the bodies of the fmt and data chunks of a WAV file. A data chunk whose size runs
+// past the end of the file, as written by streaming encoders, extends to the end of the file.
+func parseWAV(b []byte) (format []byte, data []byte, err error) {
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WAVE" {
+		return nil, nil, errors.New("missing RIFF/WAVE header")
+	}
+	for off := 12; off+8 <= len(b); {
+		id := string(b[off : off+4])
+		size := int(binary.LittleEndian.Uint32(b[off+4 : off+8]))
+		body := b[off+8:]
+		if size > len(body) || size < 0 {
+			if id != "data" {
+				return nil, nil, fmt.Errorf("%q chunk of %d bytes is truncated", id, size)
+			}
+			size = len(body)
+		}
+		switch id {
+		case "fmt ":
+			format = body[:size]
+		case "data":
+			data = body[:size]
+		}
+		off += 8 + size + size%2
+	}
+	if format == nil || data == nil {
+		return nil, nil, errors.New("missing fmt or data chunk")
+	}
+	return format, data, nil
+}
+
+func appendRIFFChunk(out []byte, id string, body []byte) []byte {
+	out = append(out, id...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// Flags of the header_type field of an Ogg page.
+const (
+	oggBOS = 0x02
+	oggEOS = 0x04
+)
+
+// oggHeaderPackets is the number of header packets, OpusHead and OpusTags, opening an Ogg Opus stream.
+const oggHeaderPackets = 2
+
+type oggPage struct {
+	headerType byte
+	granule    int64
+	serial     uint32
+	sequence   uint32
+	segments   []byte
+	body       []byte
+}
+
+// stitchOgg merges Ogg Opus files into one logical stream. The header pages of the first file are kept
+// and those of the others dropped; the remaining pages take the serial number of the first file, a
+// continuous page sequence and granule positions offset by the samples of the files before them.
+//
+// Only the first file's pre-skip is signalled in the merged header, so a decoder plays the priming samples
+// of the later files. Their granule positions are lowered by their own pre-skip, which keeps the duration
+// of the merged stream equal to the sum of the files' durations.
+func stitchOgg(parts [][]byte) ([]byte, error) {
+	var out []byte
+	var serial, sequence uint32
+	var offset int64
+	for i, part := range parts {
+		pages, err := parseOggPages(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Ogg audio %d, %w", i+1, err)
+		}
+		var shift int64
+		if i == 0 {
+			serial = pages[0].serial
+		} else {
+			shift = -int64(opusPreSkip(pages[0]))
+		}
+
+		packets := 0
+		var end int64
+		for j, page := range pages {
+			header := packets < oggHeaderPackets
+			packets += completedOggPackets(page)
+			if header && i > 0 {
+				continue
+			}
+			if !header && page.granule != -1 {
+				end = max(end, page.granule)
+				page.granule = max(0, page.granule+offset+shift)
+			}
+			page.serial = serial
+			page.sequence = sequence
+			if i > 0 || j > 0 {
+				page.headerType &^= oggBOS
+			}
+			if i < len(parts)-1 || j < len(pages)-1 {
+				page.headerType &^= oggEOS
+			}
+			out = appendOggPage(out, page)
+			sequence++
+		}
+		offset = max(0, offset+end+shift)
+	}
+	return out, nil
+}
+
+// opusPreSkip returns the pre-skip field of the OpusHead packet opening `page`, the number of 48 kHz
+// samples a decoder discards at the start of the stream, or 0 when the page holds no OpusHead.
+func opusPreSkip(page oggPage) uint16 {
+	if len(page.body) < 12 || string(page.body[:8]) != "OpusHead" {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(page.body[10:12])
+}
+
+func parseOggPages(b []byte) ([]oggPage, error) {
+	var pages []oggPage
+	for off := 0; off < len(b); {
+		if len(b)-off < 27 || string(b[off:off+4]) != "OggS" {
+			return nil, fmt.Errorf("no Ogg page at offset %d", off)
+		}
+		count := int(b[off+26])
+		if len(b)-off < 27+count {
+			return nil, fmt.Errorf("Ogg page at offset %d is truncated", off)
+		}
+		segments := b[off+27 : off+27+count]
+		size := 0
+		for _, lacing := range segments {
+			size += int(lacing)
+		}
+		bodyStart := off + 27 + count
+		if len(b)-bodyStart < size {
+			return nil, fmt.Errorf("Ogg page at offset %d is truncated", off)
+		}
+		pages = append(pages, oggPage{
+			headerType: b[off+5],
+			granule:    int64(binary.LittleEndian.Uint64(b[off+6 : off+14])),
+			serial:     binary.LittleEndian.Uint32(b[off+14 : off+18]),
+			sequence:   binary.LittleEndian.Uint32(b[off+18 : off+22]),
+			segments:   segments,
+			body:       b[bodyStart : bodyStart+size],
+		})
+		off = bodyStart + size
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no Ogg pages")
+	}
+	return pages, nil
+}
+
+// completedOggPackets returns the number of packets ending in `page`.
+func completedOggPackets(page oggPage) int {
+	n := 0
+	for _, lacing := range page.segments {
+		if lacing < 255 {
+			n++
+		}
+	}
+	return n
+}
+
+func appendOggPage(out []byte, page oggPage) []byte {
+	start := len(out)
+	out = append(out, "OggS"...)
+	out = append(out, 0, page.headerType)
+	out = binary.LittleEndian.AppendUint64(out, uint64(page.granule))
+	out = binary.LittleEndian.AppendUint32(out, page.serial)
+	out = binary.LittleEndian.AppendUint32(out, page.sequence)
+	out = binary.LittleEndian.AppendUint32(out, 0)
+	out = append(out, byte(len(page.segments)))
+	out = append(out, page.segments...)
+	out = append(out, page.body...)
+	binary.LittleEndian.PutUint32(out[start+22:start+26], oggCRC(out[start:]))
+	return out
+}
+
+var oggCRCTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := range table {
+		crc := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if crc&0x80000000 != 0 {
+				crc = crc<<1 ^ 0x04c11db7
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+// oggCRC is the checksum of an Ogg page: CRC-32 with polynomial 0x04c11db7, no reflection and a zero
+// initial value.
+func oggCRC(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// EBML element IDs of the WebM elements handled by stitchWebM.
+const (
+	ebmlHeaderID          = 0x1A45DFA3
+	webmSegmentID         = 0x18538067
+	webmSeekHeadID        = 0x114D9B74
+	webmInfoID            = 0x1549A966
+	webmTimecodeScaleID   = 0x2AD7B1
+	webmDurationID        = 0x4489
+	webmTracksID          = 0x1654AE6B
+	webmTrackEntryID      = 0xAE
+	webmDefaultDurationID = 0x23E383
+	webmClusterID         = 0x1F43B675
+	webmTimecodeID        = 0xE7
+	webmSimpleBlockID     = 0xA3
+	webmBlockGroupID      = 0xA0
+	webmBlockID           = 0xA1
+	webmCuesID            = 0x1C53BB6B
+	webmTagsID            = 0x1254C367
+	webmChaptersID        = 0x1043A770
+	webmAttachmentsID     = 0x1941A469
+)
+
+// defaultWebMFrameDuration is the duration of an Opus frame, assumed for the last block of a file
+// when the track has no default duration and there is a single block.
+const defaultWebMFrameDuration = 20_000_000 // nanoseconds
+
+type ebmlElement struct {
+	id   uint64
+	body []byte
+	// raw is the whole element, with its ID and size.
+	raw []byte
+}
+
+type webmCluster struct {
+	timecode uint64
+	// children are the raw elements of the cluster other than its timecode.
+	children [][]byte
+}
+
+type webmFile struct {
+	header        []byte
+	info          ebmlElement
+	tracks        []byte
+	clusters      []webmCluster
+	timecodeScale uint64
+	// duration is the end of the last block, in timecode units.
+	duration uint64
+}
+
+// stitchWebM merges WebM files into one segment. The EBML header, Info and Tracks of the first file
+// are kept, and the clusters of every file follow with their timecodes offset by the duration of the
+// files before them. SeekHead and Cues, whose positions no longer hold, are dropped along with Tags.
+func stitchWebM(parts [][]byte) ([]byte, error) {
+	files := make([]*webmFile, len(parts))
+	for i, part := range parts {
+		file, err := parseWebM(part)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse WebM audio %d, %w", i+1, err)
+		}
+		if i > 0 && file.timecodeScale != files[0].timecodeScale {
+			return nil, fmt.Errorf("WebM audio %d has a different timecode scale from the first", i+1)
+		}
+		files[i] = file
+	}
+
+	var segment []byte
+	var offset uint64
+	var clusters []byte
+	for _, file := range files {
+		for _, cluster := range file.clusters {
+			body := appendEBMLUint(nil, webmTimecodeID, cluster.timecode+offset)
+			for _, child := range cluster.children {
+				body = append(body, child...)
+			}
+			clusters = appendEBMLElement(clusters, webmClusterID, body)
+		}
+		offset += file.duration
+	}
+
+	first := files[0]
+	var info []byte
+	hasDuration := false
+	err := walkEBML(first.info.body, func(child ebmlElement) error {
+		if child.id == webmDurationID {
+			hasDuration = true
+			return nil
+		}
+		info = append(info, child.raw...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if hasDuration {
+		info = appendEBMLElement(info, webmDurationID, binary.BigEndian.AppendUint64(nil, math.Float64bits(float64(offset))))
+	}
+	segment = appendEBMLElement(segment, webmInfoID, info)
+	segment = append(segment, first.tracks...)
+	segment = append(segment, clusters...)
+
+	out := append([]byte(nil), first.header...)
+	return appendEBMLElement(out, webmSegmentID, segment), nil
+}
+
+func parseWebM(b []byte) (*webmFile, error) {
+	header, n, err := readEBMLElement(b, nil)
+	if err != nil {
+		return nil, err
+	}
+	if header.id != ebmlHeaderID {
+		return nil, errors.New("missing EBML header")
+	}
+	segment, _, err := readEBMLElement(b[n:], nil)
+	if err != nil {
+		return nil, err
+	}
+	if segment.id != webmSegmentID {
+		return nil, errors.New("missing Segment")
+	}
+
+	file := &webmFile{header: header.raw, timecodeScale: 1_000_000}
+	var frameDuration, lastBlock, previousBlock uint64
+	blocks := 0
+	err = walkEBMLWithin(segment.body, webmLevel1IDs, func(child ebmlElement) error {
+		switch child.id {
+		case webmInfoID:
+			file.info = child
+			return walkEBML(child.body, func(field ebmlElement) error {
+				if field.id == webmTimecodeScaleID {
+					file.timecodeScale = ebmlUint(field.body)
+				}
+				return nil
+			})
+		case webmTracksID:
+			file.tracks = child.raw
+			return walkEBML(child.body, func(entry ebmlElement) error {
+				if entry.id != webmTrackEntryID {
+					return nil
+				}
+				return walkEBML(entry.body, func(field ebmlElement) error {
+					if field.id == webmDefaultDurationID {
+						frameDuration = ebmlUint(field.body)
+					}
+					return nil
+				})
+			})
+		case webmClusterID:
+			cluster := webmCluster{}
+			err := walkEBMLWithin(child.body, webmLevel1IDs, func(field ebmlElement) error {
+				switch field.id {
+				case webmTimecodeID:
+					cluster.timecode = ebmlUint(field.body)
+					return nil
+				case webmSimpleBlockID, webmBlockGroupID:
+					timecode, err := webmBlockTimecode(field)
+					if err != nil {
+						return err
+					}
+					at := uint64(max(int64(cluster.timecode)+int64(timecode), 0))
+					previousBlock, lastBlock = lastBlock, max(lastBlock, at)
+					blocks++
+				}
+				cluster.children = append(cluster.children, field.raw)
+				return nil
+			})
+			file.clusters = append(file.clusters, cluster)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if file.info.raw == nil || file.tracks == nil {
+		return nil, errors.New("missing Info or Tracks")
+	}
+
+	// the last block lasts the track's default duration, or as long as the block before it.
+	var last uint64
+	switch {
+	case frameDuration > 0:
+		last = frameDuration / file.timecodeScale
+	case blocks > 1:
+		last = lastBlock - previousBlock
+	default:
+		last = defaultWebMFrameDuration / file.timecodeScale
+	}
+	if blocks > 0 {
+		file.duration = lastBlock + last
+	}
+	return file, nil
+}
+
+// webmLevel1IDs are the children of a Segment, which end an unknown-sized Segment child.
+var webmLevel1IDs = map[uint64]bool{
+	webmSeekHeadID: true, webmInfoID: true, webmTracksID: true, webmClusterID: true,
+	webmCuesID: true, webmTagsID: true, webmChaptersID: true, webmAttachmentsID: true,
+}
+
+// webmBlockTimecode returns the timecode of a SimpleBlock or BlockGroup, relative to its cluster.
+func webmBlockTimecode(element ebmlElement) (int16, error) {
+	block := element.body
+	if element.id == webmBlockGroupID {
+		block = nil
+		err := walkEBML(element.body, func(child ebmlElement) error {
+			if child.id == webmBlockID {
+				block = child.body
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	// the block starts with the track number, as a variable-size integer, and its 16-bit timecode.
+	_, n, err := readEBMLVint(block, false)
+	if err != nil {
+		return 0, err
+	}
+	if len(block) < n+2 {
+		return 0, errors.New("block is truncated")
+	}
+	return int16(binary.BigEndian.Uint16(block[n : n+2])), nil
+}
+
+// walkEBML calls `fn` on each element of `b`.
+func walkEBML(b []byte, fn func(ebmlElement) error) error {
+	return walkEBMLWithin(b, nil, fn)
+}
+
+// walkEBMLWithin calls `fn` on each element of `b`. An unknown-sized element ends before the first of
+// its children whose ID is in `siblings`.
+func walkEBMLWithin(b []byte, siblings map[uint64]bool, fn func(ebmlElement) error) error {
+	for len(b) > 0 {
+		element, n, err := readEBMLElement(b, siblings)
+		if err != nil {
+			return err
+		}
+		if err := fn(element); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+// readEBMLElement reads the element at the start of `b` and returns it along with its length. An
+// element of unknown size extends to the first child whose ID is in `siblings`, or to the end of `b`
+// when `siblings` is nil.
+func readEBMLElement(b []byte, siblings map[uint64]bool) (ebmlElement, int, error) {
+	id, idLen, err := readEBMLVint(b, true)
+	if err != nil {
+		return ebmlElement{}, 0, err
+	}
+	size, sizeLen, err := readEBMLVint(b[idLen:], false)
+	if err != nil {
+		return ebmlElement{}, 0, err
+	}
+	start := idLen + sizeLen
+	end := 0
+	if size == ebmlUnknownSize(sizeLen) {
+		end = len(b)
+		if siblings != nil {
+			end = start
+			for end < len(b) {
+				childID, _, err := readEBMLVint(b[end:], true)
+				if err != nil {
+					return ebmlElement{}, 0, err
+				}
+				if siblings[childID] {
+					break
+				}
+				_, n, err := readEBMLElement(b[end:], nil)
+				if err != nil {
+					return ebmlElement{}, 0, err
+				}
+				end += n
+			}
+		}
+	} else {
+		if size > uint64(len(b)-start) {
+			return ebmlElement{}, 0, fmt.Errorf("EBML element %#x of %d bytes is truncated", id, size)
+		}
+		end = start + int(size)
+	}
+	return ebmlElement{id: id, body: b[start:end], raw: b[:end]}, end, nil
+}
+
+// readEBMLVint reads a variable-size integer. IDs keep their length marker, sizes do not.
+func readEBMLVint(b []byte, keepMarker bool) (uint64, int, error) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, errors.New("invalid EBML variable-size integer")
+	}
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if len(b) < n {
+		return 0, 0, errors.New("truncated EBML variable-size integer")
+	}
+	value := uint64(b[0])
+	if !keepMarker {
+		value &= uint64(0xFF >> n)
+	}
+	for _, c := range b[1:n] {
+		value = value<<8 | uint64(c)
+	}
+	return value, n, nil
+}
+
+// ebmlUnknownSize is the reserved size value of `n` bytes, all ones, meaning the size is unknown.
+func ebmlUnknownSize(n int) uint64 {
+	return 1<<(7*n) - 1
+}
+
+func ebmlUint(b []byte) uint64 {
+	var value uint64
+	for _, c := range b {
+		value = value<<8 | uint64(c)
+	}
+	return value
+}
+
+// appendEBMLElement appends an element with an 8-byte size.
+func appendEBMLElement(out []byte, id uint64, body []byte) []byte {
+	started := false
+	for shift := 24; shift >= 0; shift -= 8 {
+		if c := byte(id >> shift); c != 0 || started {
+			out = append(out, c)
+			started = true
+		}
+	}
+	// the length marker takes the first byte of the size, leaving 56 bits for the value.
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(body)))
+	size[0] = 0x01
+	out = append(out, size...)
+	return append(out, body...)
+}
+
+func appendEBMLUint(out []byte, id uint64, value uint64) []byte {
+	return appendEBMLElement(out, id, binary.BigEndian.AppendUint64(nil, value))
+}
@@ -0,0 +1,183 @@
+package azure_cs_sdk
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWAVFormat is the fmt chunk of 24kHz 16-bit mono PCM.
+var testWAVFormat = []byte{1, 0, 1, 0, 0xC0, 0x5D, 0, 0, 0x80, 0xBB, 0, 0, 2, 0, 16, 0}
+
+func makeWAV(format, data []byte) []byte {
+	out := append([]byte("RIFF"), 0, 0, 0, 0)
+	out = append(out, "WAVE"...)
+	out = appendRIFFChunk(out, "fmt ", format)
+	out = appendRIFFChunk(out, "LIST", []byte("INFO"))
+	out = appendRIFFChunk(out, "data", data)
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out
+}
+
+func TestStitchWAV(t *testing.T) {
+	streamed := makeWAV(testWAVFormat, []byte("cd"))
+	// a streaming encoder does not know the data size.
+	binary.LittleEndian.PutUint32(streamed[len(streamed)-6:], math.MaxUint32)
+
+	out, err := stitchAudio(RIFF24khz16bitMonoPCM, [][]byte{makeWAV(testWAVFormat, []byte("ab")), streamed, makeWAV(testWAVFormat, []byte("ef"))})
+	require.NoError(t, err)
+	format, data, err := parseWAV(out)
+	require.NoError(t, err)
+	assert.Equal(t, testWAVFormat, format)
+	assert.Equal(t, "abcdef", string(data))
+	assert.EqualValues(t, len(out)-8, binary.LittleEndian.Uint32(out[4:8]))
+	assert.Len(t, out, 44+6)
+
+	other := append([]byte(nil), testWAVFormat...)
+	other[4] = 0x80
+	_, err = stitchAudio(RIFF24khz16bitMonoPCM, [][]byte{makeWAV(testWAVFormat, []byte("ab")), makeWAV(other, []byte("cd"))})
+	assert.ErrorContains(t, err, "different format")
+}
+
+func TestStitchConcatenatesStreams(t *testing.T) {
+	out, err := stitchAudio(AUDIO24khz48kbitrateMonoMP3, [][]byte{[]byte("ab"), []byte("cd")})
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(out))
+
+	_, err = stitchAudio(RAW24khz16bitMonoTruesilk, [][]byte{[]byte("ab"), []byte("cd")})
+	assert.Error(t, err)
+}
+
+// makeOgg returns an Ogg Opus file with the given serial number and pre-skip, two header pages and an
+// audio page for each granule position.
+func makeOgg(serial uint32, preSkip uint16, granules ...int64) []byte {
+	head := []byte("OpusHead\x01\x01")
+	head = binary.LittleEndian.AppendUint16(head, preSkip)
+	head = binary.LittleEndian.AppendUint32(head, 24000)
+	head = append(head, 0, 0, 0)
+	pages := []oggPage{
+		{headerType: oggBOS, segments: []byte{byte(len(head))}, body: head},
+		{segments: []byte{8}, body: []byte("OpusTags")},
+	}
+	for _, granule := range granules {
+		pages = append(pages, oggPage{granule: granule, segments: []byte{3}, body: []byte("pkt")})
+	}
+	pages[len(pages)-1].headerType |= oggEOS
+
+	var out []byte
+	for i, page := range pages {
+		page.serial = serial
+		page.sequence = uint32(i)
+		out = appendOggPage(out, page)
+	}
+	return out
+}
+
+func TestStitchOgg(t *testing.T) {
+	out, err := stitchAudio(OGG24khz16bitMonoOpus, [][]byte{makeOgg(7, 312, 960, 1920), makeOgg(9, 312, 960, 1920)})
+	require.NoError(t, err)
+
+	pages, err := parseOggPages(out)
+	require.NoError(t, err)
+	require.Len(t, pages, 6)
+	assert.Equal(t, "OpusHead", string(pages[0].body[:8]))
+	assert.EqualValues(t, 312, opusPreSkip(pages[0]))
+	assert.Equal(t, "OpusTags", string(pages[1].body))
+	var granules []int64
+	offset := 0
+	for i, page := range pages {
+		assert.EqualValues(t, 7, page.serial)
+		assert.EqualValues(t, i, page.sequence)
+		assert.Equal(t, i == 0, page.headerType&oggBOS != 0)
+		assert.Equal(t, i == len(pages)-1, page.headerType&oggEOS != 0)
+		granules = append(granules, page.granule)
+
+		// the checksum is computed with its own field zeroed.
+		size := 27 + len(page.segments) + len(page.body)
+		raw := append([]byte(nil), out[offset:offset+size]...)
+		crc := binary.LittleEndian.Uint32(raw[22:26])
+		binary.LittleEndian.PutUint32(raw[22:26], 0)
+		assert.Equal(t, oggCRC(raw), crc)
+		offset += size
+	}
+	// the second file's granules are offset by the first file's samples less its own pre-skip.
+	assert.Equal(t, []int64{0, 0, 960, 1920, 2568, 3528}, granules)
+}
+
+func TestOggCRC(t *testing.T) {
+	// the check value of CRC-32/POSIX without its final inversion, which is the Ogg checksum.
+	assert.Equal(t, uint32(0x89A1897F), oggCRC([]byte("123456789")))
+}
+
+// makeWebM returns a WebM file with an unknown-sized Segment and Cluster holding a block at each of
+// the relative timecodes.
+func makeWebM(defaultDuration uint64, timecodes ...int16) []byte {
+	unknownSize := []byte{0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}
+
+	out := appendEBMLElement(nil, ebmlHeaderID, appendEBMLElement(nil, 0x4282, []byte("webm")))
+	out = append(out, 0x18, 0x53, 0x80, 0x67)
+	out = append(out, unknownSize...)
+	out = appendEBMLElement(out, webmSeekHeadID, []byte{0xEC, 0x80})
+
+	info := appendEBMLUint(nil, webmTimecodeScaleID, 1_000_000)
+	info = appendEBMLElement(info, webmDurationID, binary.BigEndian.AppendUint64(nil, math.Float64bits(1)))
+	out = appendEBMLElement(out, webmInfoID, info)
+	var entry []byte
+	if defaultDuration > 0 {
+		entry = appendEBMLUint(nil, webmDefaultDurationID, defaultDuration)
+	}
+	out = appendEBMLElement(out, webmTracksID, appendEBMLElement(nil, webmTrackEntryID, entry))
+
+	out = append(out, 0x1F, 0x43, 0xB6, 0x75)
+	out = append(out, unknownSize...)
+	out = appendEBMLUint(out, webmTimecodeID, 0)
+	for _, timecode := range timecodes {
+		block := []byte{0x81, 0, 0, 0x80, 'o', 'p', 'u', 's'}
+		binary.BigEndian.PutUint16(block[1:3], uint16(timecode))
+		out = appendEBMLElement(out, webmSimpleBlockID, block)
+	}
+	return appendEBMLElement(out, webmCuesID, nil)
+}
+
+func TestStitchWebM(t *testing.T) {
+	out, err := stitchAudio(WEBM24khz16bitMonoOpus, [][]byte{
+		makeWebM(20_000_000, 0, 20),
+		makeWebM(0, 0, 20, 40),
+		makeWebM(0, 0),
+	})
+	require.NoError(t, err)
+
+	file, err := parseWebM(out)
+	require.NoError(t, err)
+	require.Len(t, file.clusters, 3)
+	assert.EqualValues(t, 0, file.clusters[0].timecode)
+	// the first file ends 20ms after its last block, the track's default duration.
+	assert.EqualValues(t, 40, file.clusters[1].timecode)
+	// the second file has no default duration and ends as far after its last block as the one before.
+	assert.EqualValues(t, 100, file.clusters[2].timecode)
+	assert.Len(t, file.clusters[1].children, 3)
+	assert.EqualValues(t, 120, file.duration)
+
+	var duration float64
+	require.NoError(t, walkEBML(file.info.body, func(field ebmlElement) error {
+		if field.id == webmDurationID {
+			duration = math.Float64frombits(binary.BigEndian.Uint64(field.body))
+		}
+		return nil
+	}))
+	assert.Equal(t, 120.0, duration)
+
+	// SeekHead and Cues are dropped.
+	assert.NoError(t, walkEBML(out, func(element ebmlElement) error {
+		if element.id == webmSegmentID {
+			return walkEBML(element.body, func(child ebmlElement) error {
+				assert.NotContains(t, []uint64{webmSeekHeadID, webmCuesID}, child.id)
+				return nil
+			})
+		}
+		return nil
+	}))
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type AzureCSSTT struct {
@@ -136,13 +138,37 @@ func (az *AzureCSSTT) RecognizeShortSimpleWithContext(
 	audioType AudioType,
 	language string,
 	opts ...Option,
-) (*RecognizeSimpleResponse, error) {
+) (_ *RecognizeSimpleResponse, err error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	ctx, op := az.client.opts.telemetry.start(ctx, "recognize", attrLanguage.String(language))
+	defer func() { op.end(err) }()
+
+	// the length of seekable PCM audio is known upfront, otherwise the service's word is taken for it.
+	audio, known := pcmDuration(reader, audioType)
+	defer func() {
+		az.client.recordUsage(ctx, Usage{Operation: "recognize", AudioDuration: audio, Err: err})
+	}()
+
 	req, err := az.newRecognizeShortRequest(ctx, reader, audioType, language, "simple", opts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return doAndUnmarshal[RecognizeSimpleResponse](az.client.httpClient, req)
+	resp, err := doAndUnmarshal[RecognizeSimpleResponse](az.client, req)
+	if err != nil {
+		return nil, err
+	}
+	op.setSpanAttributes(attribute.String("azure_cs.recognition_status", string(resp.RecognitionStatus)))
+	az.client.opts.telemetry.addRecognizedAudio(ctx, ticksToDuration(resp.Offset+resp.Duration), attrLanguage.String(language))
+	if !known {
+		audio = ticksToDuration(resp.Offset + resp.Duration)
+	}
+	return resp, nil
 }
 
 func (az *AzureCSSTT) newRecognizeShortRequest(
@@ -177,7 +203,7 @@ func (az *AzureCSSTT) newRecognizeShortRequest(
 		return nil, err
 	}
 	req.TransferEncoding = []string{"chunked"}
-	req.Header.Set("Authorization", "Bearer "+az.client.accessToken)
+	setSeekableBody(req, reader)
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("Expect", fmt.Sprintf("%d-continue", params.Expect))
 	switch audioType {
@@ -189,15 +215,15 @@ func (az *AzureCSSTT) newRecognizeShortRequest(
 	return req, nil
 }
 
-func doAndUnmarshal[T any](client *http.Client, req *http.Request) (*T, error) {
-	resp, err := client.Do(req)
+func doAndUnmarshal[T any](client *AzureCS, req *http.Request) (*T, error) {
+	resp, err := client.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("request failed with status code %d", resp.StatusCode)
+		return nil, newAPIError("recognize", resp, recognizeStatusDescriptions)
 	}
 
 	v := new(T)
@@ -21,8 +21,8 @@ func TestRecognizeShortSimpleReturnsPrimaryLanguage(t *testing.T) {
 	stt := &AzureCSSTT{
 		speechToTextAPI: ts.URL,
 		client: &AzureCS{
-			accessToken: "token",
-			httpClient:  http.DefaultClient,
+			tokenProvider: NewStaticTokenProvider("token"),
+			httpClient:    http.DefaultClient,
 		},
 	}
 
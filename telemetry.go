@@ -0,0 +1,174 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+	tracenoop "go.opentelemetry.io/otel/trace/noop"
+)
+
+// instrumentationName identifies this package to OpenTelemetry.
+const instrumentationName = "github.com/ho-229/azure-cs-sdk"
+
+// Attribute keys recorded on spans and metrics.
+const (
+	attrOperation    = attribute.Key("azure_cs.operation")
+	attrRegion       = attribute.Key("azure_cs.region")
+	attrVoice        = attribute.Key("azure_cs.voice")
+	attrOutputFormat = attribute.Key("azure_cs.output_format")
+	attrLanguage     = attribute.Key("azure_cs.language")
+	attrBytes        = attribute.Key("azure_cs.bytes")
+	attrStatusCode   = attribute.Key("http.response.status_code")
+	attrServer       = attribute.Key("server.address")
+	attrRequestID    = attribute.Key("azure_cs.request_id")
+)
+
+// telemetry holds the OpenTelemetry tracer and instruments of an AzureCS object. It is nil unless a
+// tracer or meter provider was configured, and a nil *telemetry records nothing.
+type telemetry struct {
+	tracer                 trace.Tracer
+	requestDuration        metric.Float64Histogram
+	firstHypothesisLatency metric.Float64Histogram
+	synthesizedCharacters  metric.Int64Counter
+	recognizedAudio        metric.Float64Counter
+	attrs                  []attribute.KeyValue // attached to every span and measurement.
+}
+
+func newTelemetry(tp trace.TracerProvider, mp metric.MeterProvider, attrs []attribute.KeyValue) *telemetry {
+	if tp == nil {
+		tp = tracenoop.NewTracerProvider()
+	}
+	if mp == nil {
+		mp = metricnoop.NewMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+
+	t := &telemetry{
+		tracer: tp.Tracer(instrumentationName),
+		attrs:  attrs,
+	}
+	// instrument creation only fails for invalid names, which are constants here; the no-op
+	// instruments returned alongside the error keep the client usable regardless.
+	t.requestDuration, _ = meter.Float64Histogram("azure_cs.request.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of requests to the Speech service."))
+	t.firstHypothesisLatency, _ = meter.Float64Histogram("azure_cs.recognition.first_hypothesis_latency",
+		metric.WithUnit("s"),
+		metric.WithDescription("Time from opening a websocket recognition to its first hypothesis."))
+	t.synthesizedCharacters, _ = meter.Int64Counter("azure_cs.synthesis.characters",
+		metric.WithUnit("{character}"),
+		metric.WithDescription("Characters of text sent for synthesis."))
+	t.recognizedAudio, _ = meter.Float64Counter("azure_cs.recognition.audio",
+		metric.WithUnit("s"),
+		metric.WithDescription("Seconds of audio recognized."))
+	return t
+}
+
+// operation is a traced and timed call to the Speech service.
+type operation struct {
+	t     *telemetry
+	name  string
+	span  trace.Span
+	start time.Time
+	attrs []attribute.KeyValue // recorded on the duration metric in addition to t.attrs.
+}
+
+// enabled reports whether anything is recorded, so that callers can skip preparing attributes.
+func (t *telemetry) enabled() bool {
+	return t != nil
+}
+
+// start begins a span named `name`. The returned context carries the span.
+func (t *telemetry) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *operation) {
+	op := &operation{t: t, name: name, start: time.Now()}
+	if t == nil {
+		op.span = trace.SpanFromContext(context.Background())
+		return ctx, op
+	}
+	ctx, op.span = t.tracer.Start(ctx, "azure_cs."+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.attrs...),
+		trace.WithAttributes(attrOperation.String(name)),
+		trace.WithAttributes(attrs...))
+	return ctx, op
+}
+
+// setAttributes records `attrs` on the span and the duration metric.
+func (op *operation) setAttributes(attrs ...attribute.KeyValue) {
+	op.span.SetAttributes(attrs...)
+	op.attrs = append(op.attrs, attrs...)
+}
+
+// setSpanAttributes records `attrs` on the span only, e.g. high-cardinality values such as byte counts.
+func (op *operation) setSpanAttributes(attrs ...attribute.KeyValue) {
+	op.span.SetAttributes(attrs...)
+}
+
+// setResponse records the status code, server and request ID of `res`.
+func (op *operation) setResponse(res *http.Response) {
+	if res == nil {
+		return
+	}
+	op.setAttributes(attrStatusCode.Int(res.StatusCode))
+	if res.Request != nil && res.Request.URL != nil {
+		op.setSpanAttributes(attrServer.String(res.Request.URL.Hostname()))
+	}
+	if id := responseRequestID(res.Header); id != "" {
+		op.setSpanAttributes(attrRequestID.String(id))
+	}
+}
+
+// end finishes the span and records the request duration. `err` marks the span as failed.
+func (op *operation) end(err error) {
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			op.setAttributes(attrStatusCode.Int(apiErr.StatusCode))
+		}
+	}
+	op.span.End()
+
+	if op.t == nil {
+		return
+	}
+	attrs := append(append([]attribute.KeyValue{attrOperation.String(op.name)}, op.t.attrs...), op.attrs...)
+	op.t.requestDuration.Record(context.Background(), time.Since(op.start).Seconds(), metric.WithAttributes(attrs...))
+}
+
+// addSynthesizedCharacters counts characters sent for synthesis.
+func (t *telemetry) addSynthesizedCharacters(ctx context.Context, n int, attrs ...attribute.KeyValue) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.synthesizedCharacters.Add(ctx, int64(n), metric.WithAttributes(append(attrs, t.attrs...)...))
+}
+
+// addRecognizedAudio counts seconds of recognized audio.
+func (t *telemetry) addRecognizedAudio(ctx context.Context, d time.Duration, attrs ...attribute.KeyValue) {
+	if t == nil || d <= 0 {
+		return
+	}
+	t.recognizedAudio.Add(ctx, d.Seconds(), metric.WithAttributes(append(attrs, t.attrs...)...))
+}
+
+// recordFirstHypothesis records the latency from opening a recognition to its first hypothesis.
+func (t *telemetry) recordFirstHypothesis(ctx context.Context, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.firstHypothesisLatency.Record(ctx, d.Seconds(), metric.WithAttributes(t.attrs...))
+}
+
+// ticksToDuration converts the 100-nanosecond ticks used for Offset and Duration by the Speech service.
+func ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * 100
+}
@@ -0,0 +1,85 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTelemetryRecordsSpansAndMetrics(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	az := srv.newClient(t, "key",
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+	)
+
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	_, err = tts.SynthesizeWithContext(context.Background(), "hello", "ar-EG-Hoda", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	_, err = tts.SynthesizeRawSsmlWithContext(context.Background(), `<speak>
+		<voice name="ar-EG-Hoda">
+			hi  there
+		</voice>
+	</speak>`, RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+
+	var names []string
+	for _, span := range spans.Ended() {
+		names = append(names, span.Name())
+		if span.Name() != "azure_cs.synthesize" {
+			continue
+		}
+		attrs := attribute.NewSet(span.Attributes()...)
+		voice, _ := attrs.Value(attrVoice)
+		assert.Equal(t, "ar-EG-Hoda", voice.AsString())
+		region, _ := attrs.Value(attrRegion)
+		assert.Equal(t, RegionWestUS2.String(), region.AsString())
+		size, _ := attrs.Value(attrBytes)
+		assert.Equal(t, int64(5), size.AsInt64())
+		requestID, _ := attrs.Value(attrRequestID)
+		assert.Equal(t, "req-1", requestID.AsString())
+	}
+	assert.Equal(t, []string{"azure_cs.token_refresh", "azure_cs.voice_list", "azure_cs.synthesize", "azure_cs.synthesize"}, names)
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	require.Contains(t, metrics, "azure_cs.request.duration")
+	assert.Len(t, metrics["azure_cs.request.duration"].(metricdata.Histogram[float64]).DataPoints, 3)
+	characters := metrics["azure_cs.synthesis.characters"].(metricdata.Sum[int64])
+	require.Len(t, characters.DataPoints, 1)
+	// "hello" and "hi there": whitespace between the SSML elements is not counted.
+	assert.Equal(t, int64(13), characters.DataPoints[0].Value)
+}
+
+func TestTelemetryDisabledByDefault(t *testing.T) {
+	var nilTelemetry *telemetry
+	_, op := nilTelemetry.start(context.Background(), "synthesize")
+	op.setAttributes(attrVoice.String("voice"))
+	op.end(nil)
+	nilTelemetry.addSynthesizedCharacters(context.Background(), 1)
+	nilTelemetry.addRecognizedAudio(context.Background(), 1)
+	nilTelemetry.recordFirstHypothesis(context.Background(), 1)
+
+	o, err := newClientOptions(RegionWestUS2, nil)
+	require.NoError(t, err)
+	assert.False(t, o.telemetry.enabled())
+	_, op = o.telemetry.start(context.Background(), "synthesize")
+	assert.False(t, op.span.IsRecording())
+	op.end(nil)
+}
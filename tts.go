@@ -9,38 +9,120 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ho-229/azure-cs-sdk/ssml"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// synthesizeActionTimeout is the amount of time the http client will wait for a response during Synthesize request
+// synthesizeActionTimeout is the default amount of time the http client will wait for a response during Synthesize request
 const synthesizeActionTimeout = time.Second * 30
 
 // AzureCSTTS stores configuration and state information for the TTS client.
 type AzureCSTTS struct {
+	voicesMu            sync.Mutex
 	regionVoiceMap      RegionVoiceMap
 	textToSpeechURL     string
 	voiceServiceListURL string
+	textToSpeechWSURL   string
 	client              *AzureCS
 }
 
+// GetVoicesMap returns the voice map, or nil if a lazily created client has not downloaded it yet.
 func (az *AzureCSTTS) GetVoicesMap() RegionVoiceMap {
+	az.voicesMu.Lock()
+	defer az.voicesMu.Unlock()
 	return az.regionVoiceMap
 }
 
-// Synthesize directs to SynthesizeWithContext. A new context.Withtimeout is created with the timeout as defined by synthesizeActionTimeout
+// DefaultVoice returns the voice set by WithDefaultVoice, or an empty string.
+func (az *AzureCSTTS) DefaultVoice() string {
+	return az.client.opts.defaultVoice
+}
+
+// DefaultOutputFormat returns the format set by WithDefaultOutputFormat, RIFF24khz16bitMonoPCM by default.
+func (az *AzureCSTTS) DefaultOutputFormat() AudioType {
+	return az.client.opts.defaultOutputFormat
+}
+
+// outputFormat returns `audioOutput`, or the default output format for DefaultAudioOutput.
+func (az *AzureCS) outputFormat(audioOutput AudioType) AudioType {
+	if audioOutput == DefaultAudioOutput {
+		return az.opts.defaultOutputFormat
+	}
+	return audioOutput
+}
+
+// Warmup fetches the token and the voice list ahead of the first synthesis.
+func (az *AzureCSTTS) Warmup(ctx context.Context) error {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer end()
+	if err := az.client.warmup(ctx); err != nil {
+		return err
+	}
+	_, err = az.voices(ctx)
+	return err
+}
+
+// voices returns the voice map, downloading it on first use. A failed download is retried by the next caller.
+func (az *AzureCSTTS) voices(ctx context.Context) (RegionVoiceMap, error) {
+	az.voicesMu.Lock()
+	defer az.voicesMu.Unlock()
+	if az.regionVoiceMap != nil {
+		return az.regionVoiceMap, nil
+	}
+
+	m, err := az.buildVoiceToRegionMap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build voice to region map, %w", err)
+	}
+	az.regionVoiceMap = m
+	az.client.health.voicesFetched(time.Now(), len(m))
+	return m, nil
+}
+
+// Synthesize directs to SynthesizeWithContext. A new context.Withtimeout is created with the timeout as defined by
+// WithSynthesizeTimeout, synthesizeActionTimeout by default.
 func (az *AzureCSTTS) Synthesize(speechText string, voiceName string, audioOutput AudioType) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), synthesizeActionTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), az.client.opts.synthesizeTimeout)
 	defer cancel()
 	return az.SynthesizeWithContext(ctx, speechText, voiceName, audioOutput)
 }
 
 // SynthesizeWithContext returns a bytestream of the rendered text-to-speech in the target audio format. `speechText` is the string of
-// text in which a user wishes to Synthesize, `region` is the language/locale
+// text in which a user wishes to Synthesize, `voiceName` is the voice, the one set by WithDefaultVoice when empty,
 // and `audioOutput` captures the audio format.
 func (az *AzureCSTTS) SynthesizeWithContext(ctx context.Context, speechText string, voiceName string, audioOutput AudioType) ([]byte, error) {
-	if _, ok := az.regionVoiceMap[voiceName]; !ok {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	voice, err := az.textVoice(ctx, speechText, voiceName)
+	if err != nil {
+		return nil, err
+	}
+	return az.synthesizeSsml(ctx, voice, audioOutput)
+}
+
+// textVoice returns the <voice> element speaking `speechText`, after checking that the voice exists.
+func (az *AzureCSTTS) textVoice(ctx context.Context, speechText string, voiceName string) (xml.Token, error) {
+	voices, err := az.voices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if voiceName == "" {
+		if voiceName = az.DefaultVoice(); voiceName == "" {
+			return nil, fmt.Errorf("no voice name given and no default voice set")
+		}
+	}
+	if _, ok := voices[voiceName]; !ok {
 		return nil, fmt.Errorf("voice name %s is not found in the voice map", voiceName)
 	}
 
@@ -51,8 +133,7 @@ func (az *AzureCSTTS) SynthesizeWithContext(ctx context.Context, speechText stri
 
 	voice := ssml.NewVoice(voiceName)
 	voice.Child = escapedBuffer.String()
-
-	return az.SynthesizeSsmlWithContext(ctx, voice, audioOutput)
+	return voice, nil
 }
 
 // SynthesizeSsmlWithContext returns a bytestream of the rendered text-to-speech in the target audio format.
@@ -62,15 +143,32 @@ func (az *AzureCSTTS) SynthesizeSsmlWithContext(
 	elems xml.Token,
 	audioOutput AudioType,
 ) ([]byte, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+	return az.synthesizeSsml(ctx, elems, audioOutput)
+}
+
+func (az *AzureCSTTS) synthesizeSsml(ctx context.Context, elems xml.Token, audioOutput AudioType) ([]byte, error) {
+	doc, err := speakDocument(elems)
+	if err != nil {
+		return nil, err
+	}
+	return az.synthesizeRawSsml(ctx, doc, audioOutput)
+}
+
+// speakDocument wraps `elems` in a <speak> element.
+func speakDocument(elems xml.Token) (string, error) {
 	doc := ssml.NewSpeak()
 	doc.Child = elems
 
 	reqBody, err := xml.Marshal(doc)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-
-	return az.SynthesizeRawSsmlWithContext(ctx, string(reqBody), audioOutput)
+	return string(reqBody), nil
 }
 
 // SynthesizeRawSsmlWithContext returns a bytestream of the rendered text-to-speech in the target audio format.
@@ -80,47 +178,117 @@ func (az *AzureCSTTS) SynthesizeRawSsmlWithContext(
 	ssml string,
 	audioOutput AudioType,
 ) ([]byte, error) {
-	request, err := http.NewRequestWithContext(ctx, http.MethodPost, az.textToSpeechURL, strings.NewReader(ssml))
+	ctx, end, err := az.client.begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	request.Header.Set("X-Microsoft-OutputFormat", audioOutput.String())
-	request.Header.Set("Content-Type", "application/ssml+xml")
-	request.Header.Set("Authorization", "Bearer "+az.client.accessToken)
-	request.Header.Set("User-Agent", "azuretts")
+	defer end()
+	return az.synthesizeRawSsml(ctx, ssml, audioOutput)
+}
 
-	response, err := az.client.httpClient.Do(request.WithContext(ctx))
+func (az *AzureCSTTS) synthesizeRawSsml(ctx context.Context, ssml string, audioOutput AudioType) ([]byte, error) {
+	audioOutput = az.client.outputFormat(audioOutput)
+	ctx, record := az.startSynthesis(ctx, ssml, audioOutput)
+	audio, response, err := az.synthesizeHedged(ctx, ssml, audioOutput)
+	record.op.setResponse(response)
+	record.finish(az.client, len(audio), err)
 	if err != nil {
 		return nil, err
 	}
+	return audio, nil
+}
+
+// synthesisRecord records the telemetry and usage of one synthesis.
+type synthesisRecord struct {
+	ctx   context.Context
+	op    *operation
+	attrs []attribute.KeyValue
+	text  string
+	usage *Usage
+}
+
+// startSynthesis starts recording the synthesis of `ssml`. The returned context carries its span.
+func (az *AzureCSTTS) startSynthesis(ctx context.Context, ssml string, audioOutput AudioType) (context.Context, *synthesisRecord) {
+	telemetry := az.client.opts.telemetry
+	record := &synthesisRecord{}
+	if telemetry.enabled() {
+		// parsing the document is only worth it when the voices and characters are recorded.
+		summary := inspectSSML(ssml)
+		record.text = summary.text
+		record.attrs = append(record.attrs, attrOutputFormat.String(audioOutput.String()))
+		if len(summary.voices) > 0 {
+			record.attrs = append(record.attrs, attrVoice.String(strings.Join(summary.voices, ",")))
+		}
+	}
+	ctx, record.op = telemetry.start(ctx, "synthesize", record.attrs...)
+	record.ctx = ctx
+	if az.client.opts.usageRecorder != nil {
+		// the voice list is needed to tell the billing tier of each voice; without it they are unknown.
+		voices, err := az.voices(ctx)
+		if err != nil {
+			az.client.logger().DebugContext(ctx, "voice types of the usage are unknown", "error", err)
+		}
+		usage := synthesisUsage(ssml, voices)
+		record.usage = &usage
+	}
+	return ctx, record
+}
+
+// finish records the outcome of the synthesis, which returned `n` bytes of audio.
+func (r *synthesisRecord) finish(az *AzureCS, n int, err error) {
+	if err == nil {
+		r.op.setSpanAttributes(attrBytes.Int(n))
+		az.opts.telemetry.addSynthesizedCharacters(r.ctx, utf8.RuneCountInString(r.text), r.attrs...)
+	}
+	r.op.end(err)
+	if r.usage != nil {
+		r.usage.Err = err
+		az.recordUsage(r.ctx, *r.usage)
+	}
+}
+
+// synthesize sends a single synthesis request for `ssml` to `url` and reads the audio. The response is
+// returned with its body closed, for its status and headers, whenever the service answered.
+func (az *AzureCS) synthesize(ctx context.Context, url string, ssml string, audioOutput AudioType) ([]byte, *http.Response, error) {
+	response, err := az.openSynthesis(ctx, url, ssml, audioOutput)
+	if err != nil {
+		return nil, response, err
+	}
 	defer response.Body.Close()
 
-	// list of acceptable response status codes
-	// see: https://docs.microsoft.com/en-us/azure/cognitive-services/speech-service/rest-text-to-speech#http-status-codes-1
-	switch response.StatusCode {
-	case http.StatusOK:
-		// The request was successful; the response body is an audio file.
-		return io.ReadAll(response.Body)
-	case http.StatusBadRequest:
-		return nil, fmt.Errorf("%d - A required parameter is missing, empty, or null. Or, the value passed to either a required or optional parameter is invalid. A common issue is a header that is too long", response.StatusCode)
-	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("%d - The request is not authorized. Check to make sure your subscription key or token is valid and in the correct region", response.StatusCode)
-	case http.StatusRequestEntityTooLarge:
-		return nil, fmt.Errorf("%d - The SSML input is longer than 1024 characters", response.StatusCode)
-	case http.StatusUnsupportedMediaType:
-		return nil, fmt.Errorf("%d - It's possible that the wrong Content-Type was provided. Content-Type should be set to application/ssml+xml", response.StatusCode)
-	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("%d - You have exceeded the quota or rate of requests allowed for your subscription", response.StatusCode)
-	case http.StatusBadGateway:
-		return nil, fmt.Errorf("%d - Network or server-side issue. May also indicate invalid headers", response.StatusCode)
+	// The request was successful; the response body is an audio file.
+	audio, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, response, err
 	}
+	return audio, response, nil
+}
 
-	return nil, fmt.Errorf("%d - received unexpected HTTP status code", response.StatusCode)
+// openSynthesis sends a single synthesis request for `ssml` to `url` and returns the response once its
+// headers arrived, with the audio left to read from its body. A response which is not 200 is returned
+// with its body closed, along with the error.
+func (az *AzureCS) openSynthesis(ctx context.Context, url string, ssml string, audioOutput AudioType) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(ssml))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("X-Microsoft-OutputFormat", audioOutput.String())
+	request.Header.Set("Content-Type", "application/ssml+xml")
+
+	response, err := az.do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		return response, newAPIError("synthesize", response, synthesizeStatusDescriptions)
+	}
+	return response, nil
 }
 
-func (az *AzureCSTTS) buildVoiceToRegionMap() (RegionVoiceMap, error) {
+func (az *AzureCSTTS) buildVoiceToRegionMap(ctx context.Context) (RegionVoiceMap, error) {
 
-	v, err := az.fetchVoiceList()
+	v, err := az.fetchVoiceList(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -132,32 +300,26 @@ func (az *AzureCSTTS) buildVoiceToRegionMap() (RegionVoiceMap, error) {
 	return m, err
 }
 
-func (az *AzureCSTTS) fetchVoiceList() ([]RegionVoice, error) {
-	req, _ := http.NewRequest(http.MethodGet, az.voiceServiceListURL, nil)
-	req.Header.Set("Authorization", "Bearer "+az.client.accessToken)
+func (az *AzureCSTTS) fetchVoiceList(ctx context.Context) (_ []RegionVoice, err error) {
+	ctx, op := az.client.opts.telemetry.start(ctx, "voice_list")
+	defer func() { op.end(err) }()
 
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, az.voiceServiceListURL, nil)
 	// Perform the request
-	res, err := az.client.httpClient.Do(req)
+	res, err := az.client.do(req)
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
+	op.setResponse(res)
 
-	switch res.StatusCode {
-	case http.StatusOK:
-		var r []RegionVoice
+	if res.StatusCode != http.StatusOK {
+		return nil, newAPIError("voice list", res, voiceListStatusDescriptions)
+	}
 
-		if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
-			return nil, fmt.Errorf("unable to decode voice list response body, %v", err)
-		}
-		return r, nil
-	case http.StatusBadRequest:
-		return nil, fmt.Errorf("%d - A required parameter is missing, empty, or null. Or, the value passed to either a required or optional parameter is invalid. A common issue is a header that is too long", res.StatusCode)
-	case http.StatusUnauthorized:
-		return nil, fmt.Errorf("%d - The request is not authorized. Check to make sure your subscription key or token is valid and in the correct region", res.StatusCode)
-	case http.StatusTooManyRequests:
-		return nil, fmt.Errorf("%d - You have exceeded the quota or rate of requests allowed for your subscription", res.StatusCode)
-	case http.StatusBadGateway:
-		return nil, fmt.Errorf("%d - Network or server-side issue. May also indicate invalid headers", res.StatusCode)
-	}
-	return nil, fmt.Errorf("%d - unexpected response code from voice list API", res.StatusCode)
+	var r []RegionVoice
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("unable to decode voice list response body, %v", err)
+	}
+	return r, nil
 }
@@ -0,0 +1,118 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// SynthesisStream is the audio of a synthesis, read as the service renders it. It is returned as soon as
+// the response headers arrive and must be closed.
+type SynthesisStream struct {
+	// ContentType is the Content-Type of the audio, e.g. audio/x-wav.
+	ContentType string
+	// RequestID is the request ID sent by the service, for support tickets.
+	RequestID string
+	// Header holds all the response headers.
+	Header http.Header
+
+	body    io.ReadCloser
+	client  *AzureCS
+	record  *synthesisRecord
+	end     func()
+	n       int
+	readErr error
+	once    sync.Once
+}
+
+// Read reads the next chunk of audio.
+func (s *SynthesisStream) Read(p []byte) (int, error) {
+	n, err := s.body.Read(p)
+	s.n += n
+	if err != nil && !errors.Is(err, io.EOF) && s.readErr == nil {
+		s.readErr = err
+	}
+	return n, err
+}
+
+// Close closes the response body. The synthesis counts as in flight for Shutdown until it is closed.
+func (s *SynthesisStream) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.body.Close()
+		s.record.finish(s.client, s.n, s.readErr)
+		s.end()
+	})
+	return err
+}
+
+// SynthesizeStreamWithContext is SynthesizeWithContext returning the audio as it is rendered, so that
+// playback can start on the first chunk. Requests are not hedged.
+func (az *AzureCSTTS) SynthesizeStreamWithContext(ctx context.Context, speechText string, voiceName string, audioOutput AudioType) (*SynthesisStream, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	voice, err := az.textVoice(ctx, speechText, voiceName)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	doc, err := speakDocument(voice)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	return az.synthesizeStream(ctx, end, doc, audioOutput)
+}
+
+// SynthesizeSsmlStreamWithContext is SynthesizeSsmlWithContext returning the audio as it is rendered.
+// Requests are not hedged.
+func (az *AzureCSTTS) SynthesizeSsmlStreamWithContext(ctx context.Context, elems xml.Token, audioOutput AudioType) (*SynthesisStream, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := speakDocument(elems)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	return az.synthesizeStream(ctx, end, doc, audioOutput)
+}
+
+// SynthesizeRawSsmlStreamWithContext is SynthesizeRawSsmlWithContext returning the audio as it is
+// rendered. Requests are not hedged.
+func (az *AzureCSTTS) SynthesizeRawSsmlStreamWithContext(ctx context.Context, ssml string, audioOutput AudioType) (*SynthesisStream, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return az.synthesizeStream(ctx, end, ssml, audioOutput)
+}
+
+// synthesizeStream opens the synthesis of `ssml`. `end` is called once the stream is closed, or straight
+// away when the request fails.
+func (az *AzureCSTTS) synthesizeStream(ctx context.Context, end func(), ssml string, audioOutput AudioType) (*SynthesisStream, error) {
+	audioOutput = az.client.outputFormat(audioOutput)
+	ctx, record := az.startSynthesis(ctx, ssml, audioOutput)
+	response, err := az.client.openSynthesis(ctx, az.textToSpeechURL, ssml, audioOutput)
+	record.op.setResponse(response)
+	if err != nil {
+		record.finish(az.client, 0, err)
+		end()
+		return nil, err
+	}
+	return &SynthesisStream{
+		ContentType: response.Header.Get("Content-Type"),
+		RequestID:   responseRequestID(response.Header),
+		Header:      response.Header,
+		body:        response.Body,
+		client:      az.client,
+		record:      record,
+		end:         end,
+	}, nil
+}
@@ -0,0 +1,95 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSynthesizeStreamReturnsOnHeaders(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "riff-24khz-16bit-mono-pcm", r.Header.Get("X-Microsoft-OutputFormat"))
+		w.Header().Set("Content-Type", "audio/x-wav")
+		w.Header().Set("X-RequestId", "req-1")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("-second"))
+	}))
+	defer ts.Close()
+
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, WithLazyInit())
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	tts.textToSpeechURL = ts.URL
+
+	stream, err := tts.SynthesizeRawSsmlStreamWithContext(context.Background(), "<speak/>", RIFF24khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio/x-wav", stream.ContentType)
+	assert.Equal(t, "req-1", stream.RequestID)
+
+	chunk := make([]byte, 5)
+	_, err = io.ReadFull(stream, chunk)
+	require.NoError(t, err)
+	assert.Equal(t, "first", string(chunk))
+
+	// the open stream keeps the client busy.
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- az.Shutdown(context.Background()) }()
+	select {
+	case <-shutdown:
+		t.Fatal("Shutdown returned while a stream was open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	rest, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, "-second", string(rest))
+	require.NoError(t, stream.Close())
+	require.NoError(t, stream.Close())
+	require.NoError(t, <-shutdown)
+}
+
+func TestSynthesizeStreamErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, WithLazyInit())
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	tts.textToSpeechURL = ts.URL
+
+	_, err = tts.SynthesizeRawSsmlStreamWithContext(context.Background(), "<speak/>", RIFF24khz16bitMonoPCM)
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	assert.Zero(t, az.lifecycle.outstanding())
+}
+
+func TestSynthesizeStreamText(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	aggregator := NewUsageAggregator()
+	az := srv.newClient(t, "key", WithLazyInit(), WithDefaultVoice("zh-CN-XiaoxiaoNeural"), WithUsageRecorder(aggregator))
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	stream, err := tts.SynthesizeStreamWithContext(context.Background(), "hello", "", RIFF24khz16bitMonoPCM)
+	require.NoError(t, err)
+	audio, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	require.NoError(t, stream.Close())
+	assert.Equal(t, "audio", string(audio))
+	assert.EqualValues(t, 5, aggregator.Totals()[""].Characters)
+}
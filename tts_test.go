@@ -1,12 +1,15 @@
 package azure_cs_sdk
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // func TestVoiceXML(t *testing.T) {
@@ -43,7 +46,7 @@ import (
 
 // TestRefreshToken validates logic for fetching of the refreshToken
 func TestRefreshToken(t *testing.T) {
-	az := &AzureCS{
+	p := &IssueTokenProvider{
 		httpClient:      http.DefaultClient,
 		subscriptionKey: "ThisIsMySubscriptionKeyAndToBeToken",
 	}
@@ -51,15 +54,15 @@ func TestRefreshToken(t *testing.T) {
 	ts := httptest.NewServer(
 		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			// return the SubscriptionKey as the token, for test case only.
-			w.Write([]byte(az.subscriptionKey))
+			w.Write([]byte(p.subscriptionKey))
 		}),
 	)
 	defer ts.Close()
-	az.tokenRefreshURL = ts.URL
-	err := az.refreshToken()
+	p.tokenRefreshURL = ts.URL
+	err := p.Refresh(context.Background())
 
 	assert.NoError(t, err, "should not return an error")
-	assert.Equal(t, az.subscriptionKey, az.accessToken, "values should be equal")
+	assert.Equal(t, p.subscriptionKey, p.accessToken, "values should be equal")
 }
 
 func TestFetchVoiceList(t *testing.T) {
@@ -71,13 +74,12 @@ func TestFetchVoiceList(t *testing.T) {
 
 	az := &AzureCSTTS{
 		client: &AzureCS{
-			subscriptionKey: "SYS64738",
-			accessToken:     "SYS49152",
-			httpClient:      http.DefaultClient,
+			tokenProvider: NewStaticTokenProvider("SYS49152"),
+			httpClient:    http.DefaultClient,
 		},
 		voiceServiceListURL: ts.URL,
 	}
-	vl, err := az.fetchVoiceList()
+	vl, err := az.fetchVoiceList(context.Background())
 	if err != nil {
 		t.Errorf("received error %v", err)
 	}
@@ -85,6 +87,90 @@ func TestFetchVoiceList(t *testing.T) {
 
 }
 
+func TestLazyInitDefersNetworkCalls(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	az := srv.newClient(t, "key", WithLazyInit())
+
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	assert.Nil(t, tts.GetVoicesMap())
+	assert.Equal(t, int32(0), srv.tokenCalls.Load())
+
+	b, err := tts.SynthesizeWithContext(context.Background(), "hello", "zh-CN-XiaoxiaoNeural", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(b))
+	assert.Len(t, tts.GetVoicesMap(), 5)
+	assert.Equal(t, int32(1), srv.tokenCalls.Load())
+	assert.Equal(t, int32(1), srv.voiceCalls.Load())
+}
+
+func TestLazyInitSurfacesErrorsAtCallTime(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	provider := NewIssueTokenProvider(http.DefaultClient, "key", ts.URL)
+	provider.retryPolicy = NoRetryPolicy()
+	az, cleanup, err := NewWithTokenProvider(http.DefaultClient, provider, RegionWestUS2, WithLazyInit())
+	require.NoError(t, err)
+	defer cleanup()
+
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	err = tts.Warmup(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch initial token")
+}
+
+// testSpeechServer stands in for the token, voice list and synthesis endpoints of the Speech service.
+// Synthesis answers "audio" with the request ID "req-1".
+type testSpeechServer struct {
+	*httptest.Server
+	tokenCalls      atomic.Int32
+	voiceCalls      atomic.Int32
+	synthesizeCalls atomic.Int32
+}
+
+// newTestSpeechServer starts a testSpeechServer which issues `token` and passes every request to
+// `check` when it is not nil.
+func newTestSpeechServer(t *testing.T, token string, check func(*http.Request)) *testSpeechServer {
+	srv := &testSpeechServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if check != nil {
+			check(r)
+		}
+		switch r.URL.Path {
+		case "/token":
+			srv.tokenCalls.Add(1)
+			w.Write([]byte(token))
+		case "/tts/voices/list":
+			srv.voiceCalls.Add(1)
+			fmt.Fprintln(w, voiceListAPIGoodResponse)
+		case "/tts/v1":
+			srv.synthesizeCalls.Add(1)
+			w.Header().Set("X-RequestId", "req-1")
+			w.Write([]byte("audio"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newClient returns an AzureCS object which fetches its token from and synthesizes through `srv`.
+func (srv *testSpeechServer) newClient(t *testing.T, subscriptionKey string, opts ...ClientOption) *AzureCS {
+	opts = append([]ClientOption{
+		WithTokenRefreshAPI(srv.URL + "/token"),
+		WithTextToSpeechAPI(srv.URL + "/tts"),
+	}, opts...)
+	az, cleanup, err := NewWithClient(http.DefaultClient, subscriptionKey, RegionWestUS2, opts...)
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+	return az
+}
+
 // sample response taken from https://docs.microsoft.com/en-us/azure/cognitive-services/speech-service/rest-text-to-speech#sample-response
 const voiceListAPIGoodResponse string = `[
     {
@@ -0,0 +1,309 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Usage describes what a single Synthesize* or Recognize* call consumed, for cost accounting. It is
+// passed to the UsageRecorder set through WithUsageRecorder after every call.
+type Usage struct {
+	// Operation is "synthesize", "recognize" for short audio or "recognize_stream" for websocket recognition.
+	Operation string
+	// Tag is the caller-supplied tag set through ContextWithUsageTag, or empty.
+	Tag string
+	// Characters is the number of billable characters sent for synthesis; see BillableCharacters.
+	Characters int
+	// Voices breaks Characters down by voice, in document order.
+	Voices []VoiceUsage
+	// AudioDuration is the length of the audio sent for recognition.
+	AudioDuration time.Duration
+	// Err is the error the call returned. Failed calls are reported too, but are not billed.
+	Err error
+}
+
+// VoiceUsage is the share of a synthesis spoken by one voice.
+type VoiceUsage struct {
+	Name string
+	// Type is the billing tier of the voice from the voice list, or VoiceUnknown.
+	Type       VoiceType
+	Characters int
+}
+
+// UsageRecorder receives the Usage of every Synthesize* and Recognize* call. Implementations must be
+// safe for concurrent use and should return quickly, as they run on the calling goroutine.
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, usage Usage)
+}
+
+// UsageRecorderFunc adapts a function to the UsageRecorder interface.
+type UsageRecorderFunc func(ctx context.Context, usage Usage)
+
+// RecordUsage calls f.
+func (f UsageRecorderFunc) RecordUsage(ctx context.Context, usage Usage) {
+	f(ctx, usage)
+}
+
+type usageTagKey struct{}
+
+// ContextWithUsageTag returns a context which tags the Usage of calls made with it, e.g. with the feature
+// the call was made for.
+func ContextWithUsageTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, usageTagKey{}, tag)
+}
+
+func usageTag(ctx context.Context) string {
+	tag, _ := ctx.Value(usageTagKey{}).(string)
+	return tag
+}
+
+// recordUsage hands `usage` to the configured UsageRecorder, if any.
+func (az *AzureCS) recordUsage(ctx context.Context, usage Usage) {
+	if az.opts.usageRecorder == nil {
+		return
+	}
+	usage.Tag = usageTag(ctx)
+	az.opts.usageRecorder.RecordUsage(ctx, usage)
+}
+
+// BillableCharacters counts the characters of an SSML document billed for synthesis, following
+// https://learn.microsoft.com/en-us/azure/ai-services/speech-service/text-to-speech#billable-characters :
+// everything inside the <voice> elements counts, markup and white space included, but the <speak> and
+// <voice> tags themselves do not. Chinese characters, including Japanese kanji and Korean hanja, count twice.
+func BillableCharacters(ssml string) int {
+	n := 0
+	for _, span := range billableSpans(ssml) {
+		n += span.characters
+	}
+	return n
+}
+
+// billableSpan is the content of one <voice> element.
+type billableSpan struct {
+	voice      string
+	characters int
+}
+
+// billableSpans returns the billable content of every <voice> element of `doc`. A document without
+// voices is billed for the content of <speak>, and plain text in full.
+func billableSpans(doc string) []billableSpan {
+	spans := elementSpans(doc, "voice")
+	if len(spans) == 0 {
+		spans = elementSpans(doc, "speak")
+	}
+	if len(spans) == 0 && !strings.HasPrefix(strings.TrimSpace(doc), "<") {
+		spans = []billableSpan{{characters: countBillable(doc)}}
+	}
+	return spans
+}
+
+// elementSpans returns the billable characters of the raw inner XML of every `name` element of `doc`.
+func elementSpans(doc string, name string) []billableSpan {
+	var spans []billableSpan
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	var voice string
+	start := int64(-1)
+	for {
+		before := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			return spans
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local != name || start >= 0 {
+				continue
+			}
+			voice = ""
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "name" {
+					voice = attr.Value
+				}
+			}
+			start = decoder.InputOffset()
+		case xml.EndElement:
+			if t.Name.Local != name || start < 0 {
+				continue
+			}
+			spans = append(spans, billableSpan{voice: voice, characters: countBillable(doc[start:before])})
+			start = -1
+		}
+	}
+}
+
+func countBillable(s string) int {
+	n := 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// synthesisUsage builds the Usage of synthesizing `ssml`, looking the voice types up in `voices`.
+func synthesisUsage(ssml string, voices RegionVoiceMap) Usage {
+	usage := Usage{Operation: "synthesize"}
+	for _, span := range billableSpans(ssml) {
+		usage.Characters += span.characters
+		usage.Voices = append(usage.Voices, VoiceUsage{
+			Name:       span.voice,
+			Type:       voiceType(span.voice, voices),
+			Characters: span.characters,
+		})
+	}
+	return usage
+}
+
+// voiceType returns the type of the voice named `name` from the voice list, or VoiceUnknown when the
+// voice is not listed.
+func voiceType(name string, voices RegionVoiceMap) VoiceType {
+	if v, ok := voices[name]; ok {
+		return v.VoiceType
+	}
+	return VoiceUnknown
+}
+
+// rawPCMByteRates are the byte rates of the audio formats without a header.
+var rawPCMByteRates = map[AudioType]int64{
+	RAW8khz8bitMonoMulaw: 8000,
+	RAW8khz8bitMonoAlaw:  8000,
+	RAW16khz16bitMonoPCM: 32000,
+	RAW24khz16bitMonoPCM: 48000,
+	RAW48khz16bitMonoPCM: 96000,
+}
+
+// maxWAVHeaderSize bounds how much of a WAV file is read to find its data chunk.
+const maxWAVHeaderSize = 4096
+
+// pcmDuration returns the length of the PCM audio remaining in `reader`, when its size can be determined
+// without reading it. The byte rate of RIFF audio is taken from its WAV header, which is read and rewound.
+func pcmDuration(reader io.Reader, audioType AudioType) (time.Duration, bool) {
+	byteRate, raw := rawPCMByteRates[audioType]
+	riff := strings.HasPrefix(audioType.String(), "riff-")
+	if !raw && !riff {
+		return 0, false
+	}
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return 0, false
+	}
+	offset, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+	end, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+	if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+		return 0, false
+	}
+	size := end - offset
+
+	if riff {
+		header := make([]byte, min(size, maxWAVHeaderSize))
+		n, _ := io.ReadFull(reader, header)
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return 0, false
+		}
+		var dataOffset int64
+		if byteRate, dataOffset, ok = wavDataLayout(header[:n]); !ok {
+			return 0, false
+		}
+		size -= dataOffset
+	}
+	return time.Duration(max(size, 0)) * time.Second / time.Duration(byteRate), true
+}
+
+// wavDataLayout returns the byte rate of the WAV file starting with `header` and the offset of its audio
+// data. The size of the data chunk is not used, as streaming encoders leave it unset.
+func wavDataLayout(header []byte) (int64, int64, bool) {
+	if len(header) < 12 || string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		return 0, 0, false
+	}
+	var byteRate int64
+	for pos := 12; pos+8 <= len(header); {
+		id := string(header[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(header[pos+4 : pos+8]))
+		switch {
+		case id == "fmt " && size >= 16 && pos+8+16 <= len(header):
+			byteRate = int64(binary.LittleEndian.Uint32(header[pos+16 : pos+20]))
+		case id == "data":
+			return byteRate, int64(pos + 8), byteRate > 0
+		}
+		if size < 0 || size > len(header) {
+			return 0, 0, false
+		}
+		pos += 8 + size + size&1
+	}
+	return 0, 0, false
+}
+
+// UsageTotals sums the Usage recorded under one tag.
+type UsageTotals struct {
+	// Calls counts every recorded call, Failures the ones which returned an error.
+	Calls    int64
+	Failures int64
+	// Characters and CharactersByVoiceType sum the billable characters of successful syntheses.
+	Characters            int64
+	CharactersByVoiceType map[VoiceType]int64
+	// AudioDuration sums the audio of successful recognitions.
+	AudioDuration time.Duration
+}
+
+// UsageAggregator is a UsageRecorder which keeps per-tag totals in memory.
+type UsageAggregator struct {
+	mu     sync.Mutex
+	totals map[string]*UsageTotals
+}
+
+// NewUsageAggregator returns an empty UsageAggregator.
+func NewUsageAggregator() *UsageAggregator {
+	return &UsageAggregator{totals: make(map[string]*UsageTotals)}
+}
+
+// RecordUsage adds `usage` to the totals of its tag.
+func (a *UsageAggregator) RecordUsage(_ context.Context, usage Usage) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t, ok := a.totals[usage.Tag]
+	if !ok {
+		t = &UsageTotals{CharactersByVoiceType: make(map[VoiceType]int64)}
+		a.totals[usage.Tag] = t
+	}
+	t.Calls++
+	if usage.Err != nil {
+		t.Failures++
+		return
+	}
+	t.Characters += int64(usage.Characters)
+	for _, v := range usage.Voices {
+		t.CharactersByVoiceType[v.Type] += int64(v.Characters)
+	}
+	t.AudioDuration += usage.AudioDuration
+}
+
+// Totals returns a copy of the totals, keyed by tag. Untagged calls are summed under "".
+func (a *UsageAggregator) Totals() map[string]UsageTotals {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	totals := make(map[string]UsageTotals, len(a.totals))
+	for tag, t := range a.totals {
+		c := *t
+		c.CharactersByVoiceType = make(map[VoiceType]int64, len(t.CharactersByVoiceType))
+		for k, v := range t.CharactersByVoiceType {
+			c.CharactersByVoiceType[k] = v
+		}
+		totals[tag] = c
+	}
+	return totals
+}
@@ -0,0 +1,151 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBillableCharacters(t *testing.T) {
+	tests := []struct {
+		name string
+		ssml string
+		want int
+	}{
+		{"plain text", "hello", 5},
+		{"voice content", `<speak version="1.0"><voice name="en-US-JennyNeural">hello world</voice></speak>`, 11},
+		{"markup counts", `<speak><voice name="v"><break time="1s"/>hi</voice></speak>`, 20},
+		{"white space counts", "<speak><voice name=\"v\">\n  hi\n</voice></speak>", 6},
+		{"han counts twice", `<speak><voice name="zh-CN-XiaoxiaoNeural">你好!</voice></speak>`, 5},
+		{"several voices", `<speak><voice name="a">ab</voice><voice name="b">cde</voice></speak>`, 5},
+		{"no voice", `<speak>hi there</speak>`, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, BillableCharacters(tt.ssml))
+		})
+	}
+}
+
+func TestUsageAggregatorTotalsByTag(t *testing.T) {
+	srv := newTestSpeechServer(t, "token", nil)
+	aggregator := NewUsageAggregator()
+	az := srv.newClient(t, "key", WithUsageRecorder(aggregator))
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+
+	ctx := ContextWithUsageTag(context.Background(), "customer-a")
+	_, err = tts.SynthesizeRawSsmlWithContext(ctx, `<speak><voice name="zh-CN-XiaoxiaoNeural">你好</voice><voice name="ar-EG-Hoda">hi</voice></speak>`, RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	_, err = tts.SynthesizeWithContext(ctx, "hello", "ar-EG-Hoda", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+	_, err = tts.SynthesizeWithContext(context.Background(), "hi", "zh-CN-XiaoxiaoNeural", RIFF16khz16bitMonoPCM)
+	require.NoError(t, err)
+
+	totals := aggregator.Totals()
+	require.Len(t, totals, 2)
+	a := totals["customer-a"]
+	assert.EqualValues(t, 2, a.Calls)
+	assert.EqualValues(t, 11, a.Characters)
+	assert.Equal(t, map[VoiceType]int64{VoiceNeural: 4, VoiceStandard: 7}, a.CharactersByVoiceType)
+	assert.EqualValues(t, 1, totals[""].Calls)
+	assert.EqualValues(t, 2, totals[""].CharactersByVoiceType[VoiceNeural])
+}
+
+func TestUsageRecordsFailedSynthesis(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	var usages []Usage
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, WithLazyInit(),
+		WithUsageRecorder(UsageRecorderFunc(func(_ context.Context, usage Usage) { usages = append(usages, usage) })))
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	tts.textToSpeechURL = ts.URL
+
+	_, err = tts.SynthesizeRawSsmlWithContext(context.Background(), `<speak><voice name="en-US-Ava:DragonHDLatestNeural">hi</voice></speak>`, RIFF16khz16bitMonoPCM)
+	require.Error(t, err)
+	require.Len(t, usages, 1)
+	assert.Equal(t, "synthesize", usages[0].Operation)
+	// the voice list could not be downloaded either, so the type of the voice is not guessed.
+	assert.Equal(t, []VoiceUsage{{Name: "en-US-Ava:DragonHDLatestNeural", Type: VoiceUnknown, Characters: 2}}, usages[0].Voices)
+	assert.Error(t, usages[0].Err)
+}
+
+func TestUsageRecordsRecognizedAudioDuration(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"RecognitionStatus":"Success","DisplayText":"hello","Offset":10000000,"Duration":20000000}`)
+	}))
+	defer ts.Close()
+
+	aggregator := NewUsageAggregator()
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2, WithLazyInit(),
+		WithSpeechToTextAPI(ts.URL), WithUsageRecorder(aggregator))
+	require.NoError(t, err)
+	stt, err := az.NewSTT()
+	require.NoError(t, err)
+
+	// half a second of PCM behind a wav header.
+	wav := makeWAV(wavFormat(16000, 1, 16), make([]byte, defaultWAVByteRate/2))
+	_, err = stt.RecognizeShortSimple(bytes.NewReader(wav), RIFF16khz16bitMonoPCM, "en-US")
+	require.NoError(t, err)
+	// without a known size, the service's offset and duration are used.
+	_, err = stt.RecognizeShortSimple(bytes.NewBufferString("opus"), OGG16khz16bitMonoOpus, "en-US")
+	require.NoError(t, err)
+
+	assert.Equal(t, 3500*time.Millisecond, aggregator.Totals()[""].AudioDuration)
+}
+
+// wavFormat returns the fmt chunk of PCM audio.
+func wavFormat(sampleRate, channels, bits int) []byte {
+	format := make([]byte, 16)
+	binary.LittleEndian.PutUint16(format[0:2], 1)
+	binary.LittleEndian.PutUint16(format[2:4], uint16(channels))
+	binary.LittleEndian.PutUint32(format[4:8], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(format[8:12], uint32(sampleRate*channels*bits/8))
+	binary.LittleEndian.PutUint16(format[12:14], uint16(channels*bits/8))
+	binary.LittleEndian.PutUint16(format[14:16], uint16(bits))
+	return format
+}
+
+func TestPCMDuration(t *testing.T) {
+	tests := []struct {
+		name      string
+		audio     []byte
+		audioType AudioType
+		want      time.Duration
+		known     bool
+	}{
+		{"8k mulaw wav", makeWAV(wavFormat(8000, 1, 8), make([]byte, 4000)), RIFF8khz8bitMonoMulaw, 500 * time.Millisecond, true},
+		{"16k wav", makeWAV(wavFormat(16000, 1, 16), make([]byte, 16000)), RIFF16khz16bitMonoPCM, 500 * time.Millisecond, true},
+		{"24k wav", makeWAV(wavFormat(24000, 1, 16), make([]byte, 96000)), RIFF24khz16bitMonoPCM, 2 * time.Second, true},
+		{"48k stereo wav", makeWAV(wavFormat(48000, 2, 16), make([]byte, 19200)), RIFF48khz16bitMonoPCM, 100 * time.Millisecond, true},
+		{"8k raw", make([]byte, 8000), RAW8khz8bitMonoAlaw, time.Second, true},
+		{"24k raw", make([]byte, 24000), RAW24khz16bitMonoPCM, 500 * time.Millisecond, true},
+		{"48k raw", make([]byte, 9600), RAW48khz16bitMonoPCM, 100 * time.Millisecond, true},
+		{"not a wav", []byte("definitely not a wav file"), RIFF16khz16bitMonoPCM, 0, false},
+		{"compressed", []byte("opus"), OGG16khz16bitMonoOpus, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bytes.NewReader(tt.audio)
+			d, known := pcmDuration(reader, tt.audioType)
+			assert.Equal(t, tt.known, known)
+			assert.Equal(t, tt.want, d)
+			// the header is left for the request.
+			assert.EqualValues(t, len(tt.audio), reader.Len())
+		})
+	}
+}
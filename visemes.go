@@ -0,0 +1,234 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/ho-229/azure-cs-sdk/ssml"
+)
+
+// BlendShapeFrameRate is the number of blendshape frames per second of audio.
+const BlendShapeFrameRate = 60
+
+// BlendShapeCount is the number of weights in a blendshape frame.
+const BlendShapeCount = 55
+
+// BlendShapeNames names the weights of a blendshape frame, in order.
+// See https://learn.microsoft.com/en-us/azure/ai-services/speech-service/how-to-speech-synthesis-viseme#3d-blend-shapes
+var BlendShapeNames = [BlendShapeCount]string{
+	"eyeBlinkLeft", "eyeLookDownLeft", "eyeLookInLeft", "eyeLookOutLeft", "eyeLookUpLeft", "eyeSquintLeft", "eyeWideLeft",
+	"eyeBlinkRight", "eyeLookDownRight", "eyeLookInRight", "eyeLookOutRight", "eyeLookUpRight", "eyeSquintRight", "eyeWideRight",
+	"jawForward", "jawLeft", "jawRight", "jawOpen",
+	"mouthClose", "mouthFunnel", "mouthPucker", "mouthLeft", "mouthRight",
+	"mouthSmileLeft", "mouthSmileRight", "mouthFrownLeft", "mouthFrownRight",
+	"mouthDimpleLeft", "mouthDimpleRight", "mouthStretchLeft", "mouthStretchRight",
+	"mouthRollLower", "mouthRollUpper", "mouthShrugLower", "mouthShrugUpper",
+	"mouthPressLeft", "mouthPressRight", "mouthLowerDownLeft", "mouthLowerDownRight", "mouthUpperUpLeft", "mouthUpperUpRight",
+	"browDownLeft", "browDownRight", "browInnerUp", "browOuterUpLeft", "browOuterUpRight",
+	"cheekPuff", "cheekSquintLeft", "cheekSquintRight", "noseSneerLeft", "noseSneerRight",
+	"tongueOut", "headRoll", "leftEyeRoll", "rightEyeRoll",
+}
+
+// BlendShapeFrame holds the facial blendshape weights at Offset from the start of the audio.
+type BlendShapeFrame struct {
+	Offset time.Duration
+	// Weights are between 0 and 1, in the order of BlendShapeNames.
+	Weights [BlendShapeCount]float64
+}
+
+// VisemeSynthesis is the audio of a synthesis along with its lip-sync data.
+type VisemeSynthesis struct {
+	Audio []byte
+	// Visemes holds the viseme IDs in order of their offsets. Events carrying animation data are decoded
+	// into BlendShapes instead.
+	Visemes []Viseme
+	// BlendShapes holds the frames in order of their offsets. It is only filled when the document contains
+	// an mstts:viseme element of type FacialExpression.
+	BlendShapes []BlendShapeFrame
+}
+
+// wsAnimationChunk is the animation of a viseme event of type FacialExpression.
+type wsAnimationChunk struct {
+	FrameIndex  int
+	BlendShapes [][]float64
+}
+
+// SynthesizeVisemesWithContext synthesizes `speechText` over the websocket API and returns the audio
+// along with the viseme track. When `visemeType` is not empty, an mstts:viseme element of that type is
+// added to the document; VisemeTypeFacialExpression fills the blendshape frames.
+func (az *AzureCSTTS) SynthesizeVisemesWithContext(ctx context.Context, speechText string, voiceName string, visemeType ssml.VisemeType, audioOutput AudioType) (*VisemeSynthesis, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	voice, err := az.textVoice(ctx, speechText, voiceName)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	if visemeType != "" {
+		voice, err = withViseme(voice.(ssml.Voice), visemeType)
+		if err != nil {
+			end()
+			return nil, err
+		}
+	}
+	doc, err := speakDocument(voice)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	events, err := az.synthesizeEvents(ctx, end, doc, audioOutput)
+	if err != nil {
+		return nil, err
+	}
+	return collectVisemeSynthesis(events)
+}
+
+// SynthesizeSsmlVisemesWithContext is SynthesizeVisemesWithContext for an SSML document, which requests
+// animation data through an ssml.Viseme element.
+func (az *AzureCSTTS) SynthesizeSsmlVisemesWithContext(ctx context.Context, elems xml.Token, audioOutput AudioType) (*VisemeSynthesis, error) {
+	events, err := az.SynthesizeSsmlEventsWithContext(ctx, elems, audioOutput)
+	if err != nil {
+		return nil, err
+	}
+	return collectVisemeSynthesis(events)
+}
+
+// SynthesizeRawSsmlVisemesWithContext is SynthesizeVisemesWithContext for a raw SSML document.
+func (az *AzureCSTTS) SynthesizeRawSsmlVisemesWithContext(ctx context.Context, ssml string, audioOutput AudioType) (*VisemeSynthesis, error) {
+	events, err := az.SynthesizeRawSsmlEventsWithContext(ctx, ssml, audioOutput)
+	if err != nil {
+		return nil, err
+	}
+	return collectVisemeSynthesis(events)
+}
+
+// withViseme puts an mstts:viseme element ahead of the escaped text of `voice`.
+func withViseme(voice ssml.Voice, visemeType ssml.VisemeType) (ssml.Voice, error) {
+	element, err := xml.Marshal(ssml.NewViseme(visemeType))
+	if err != nil {
+		return voice, err
+	}
+	text, _ := voice.Child.(string)
+	voice.Child = string(element) + text
+	return voice, nil
+}
+
+// collectVisemeSynthesis reads the events of a session until the channel is closed.
+func collectVisemeSynthesis(events <-chan SynthesisEvent) (*VisemeSynthesis, error) {
+	result := &VisemeSynthesis{}
+	var err error
+	for event := range events {
+		switch event.Type {
+		case SynthesisEventAudio:
+			result.Audio = append(result.Audio, event.Audio...)
+		case SynthesisEventViseme:
+			if event.Viseme.Animation == "" {
+				result.Visemes = append(result.Visemes, *event.Viseme)
+				continue
+			}
+			frames, decodeErr := decodeBlendShapes(event.Viseme.Animation)
+			if decodeErr != nil && err == nil {
+				err = decodeErr
+			}
+			result.BlendShapes = append(result.BlendShapes, frames...)
+		case SynthesisEventError:
+			if err == nil {
+				err = event.Err
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(result.Visemes, func(i, j int) bool {
+		return result.Visemes[i].Offset < result.Visemes[j].Offset
+	})
+	sort.SliceStable(result.BlendShapes, func(i, j int) bool {
+		return result.BlendShapes[i].Offset < result.BlendShapes[j].Offset
+	})
+	return result, nil
+}
+
+// decodeBlendShapes decodes the frames of an animation chunk. Frames are numbered from the start of the
+// audio at BlendShapeFrameRate.
+func decodeBlendShapes(animation string) ([]BlendShapeFrame, error) {
+	var chunk wsAnimationChunk
+	if err := json.Unmarshal([]byte(animation), &chunk); err != nil {
+		return nil, fmt.Errorf("failed to decode viseme animation: %w", err)
+	}
+	frames := make([]BlendShapeFrame, len(chunk.BlendShapes))
+	for i, weights := range chunk.BlendShapes {
+		if len(weights) != BlendShapeCount {
+			return nil, fmt.Errorf("blendshape frame %d has %d weights, expected %d", chunk.FrameIndex+i, len(weights), BlendShapeCount)
+		}
+		frames[i].Offset = blendShapeFrameOffset(chunk.FrameIndex + i)
+		copy(frames[i].Weights[:], weights)
+	}
+	return frames, nil
+}
+
+func blendShapeFrameOffset(index int) time.Duration {
+	return time.Duration(index) * time.Second / BlendShapeFrameRate
+}
+
+// AnimationTrack is the JSON animation track written by VisemeSynthesis.WriteAnimationTrack. Times are
+// in seconds from the start of the audio.
+type AnimationTrack struct {
+	Duration        float64              `json:"duration"`
+	Visemes         []AnimationVisemeKey `json:"visemes"`
+	FrameRate       int                  `json:"frameRate,omitempty"`
+	BlendShapeNames []string             `json:"blendShapeNames,omitempty"`
+	Frames          []AnimationFrame     `json:"frames,omitempty"`
+}
+
+// AnimationVisemeKey is a viseme keyframe of an AnimationTrack.
+type AnimationVisemeKey struct {
+	Time float64 `json:"time"`
+	ID   int     `json:"id"`
+}
+
+// AnimationFrame is a blendshape keyframe of an AnimationTrack, with its weights in the order of
+// AnimationTrack.BlendShapeNames.
+type AnimationFrame struct {
+	Time    float64   `json:"time"`
+	Weights []float64 `json:"weights"`
+}
+
+// AnimationTrack returns the viseme track and blendshape frames as an AnimationTrack. Its Duration runs
+// to the last keyframe.
+func (s *VisemeSynthesis) AnimationTrack() AnimationTrack {
+	track := AnimationTrack{Visemes: make([]AnimationVisemeKey, len(s.Visemes))}
+	var duration time.Duration
+	for i, viseme := range s.Visemes {
+		track.Visemes[i] = AnimationVisemeKey{Time: viseme.Offset.Seconds(), ID: viseme.ID}
+		duration = max(duration, viseme.Offset)
+	}
+	if len(s.BlendShapes) > 0 {
+		track.FrameRate = BlendShapeFrameRate
+		track.BlendShapeNames = append([]string(nil), BlendShapeNames[:]...)
+		track.Frames = make([]AnimationFrame, len(s.BlendShapes))
+		for i := range s.BlendShapes {
+			frame := &s.BlendShapes[i]
+			track.Frames[i] = AnimationFrame{Time: frame.Offset.Seconds(), Weights: frame.Weights[:]}
+			duration = max(duration, frame.Offset)
+		}
+	}
+	track.Duration = duration.Seconds()
+	return track
+}
+
+// WriteAnimationTrack writes the AnimationTrack to `w` as JSON.
+func (s *VisemeSynthesis) WriteAnimationTrack(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(s.AnimationTrack()); err != nil {
+		return fmt.Errorf("failed to write animation track: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package azure_cs_sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ho-229/azure-cs-sdk/ssml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// animationChunk returns the JSON animation chunk of `frames` frames starting at `index`, whose first
+// weight is the frame index.
+func animationChunk(index, frames int) string {
+	chunk := wsAnimationChunk{FrameIndex: index}
+	for i := 0; i < frames; i++ {
+		weights := make([]float64, BlendShapeCount)
+		weights[0] = float64(index + i)
+		chunk.BlendShapes = append(chunk.BlendShapes, weights)
+	}
+	data, _ := json.Marshal(chunk)
+	return string(data)
+}
+
+func visemeMetadata(offset uint64, id int, animation string) string {
+	animationJSON, _ := json.Marshal(animation)
+	return fmt.Sprintf(`{"Metadata":[{"Type":"Viseme","Data":{"Offset":%d,"VisemeId":%d,"AnimationChunk":%s}}]}`, offset, id, animationJSON)
+}
+
+func TestSynthesizeVisemes(t *testing.T) {
+	tts := newTestSynthesisClient(t, func(conn *websocket.Conn) {
+		for _, path := range []string{"speech.config", "synthesis.context", "ssml"} {
+			_, frame, err := conn.ReadMessage()
+			require.NoError(t, err)
+			message, err := parseWSTextMessage(frame)
+			require.NoError(t, err)
+			assert.Equal(t, path, message.headers["path"])
+			if path == "ssml" {
+				assert.Contains(t, message.body, `<mstts:viseme type="FacialExpression"></mstts:viseme><lang xml:lang="en-US">hello</lang>`)
+			}
+		}
+
+		for _, body := range []string{
+			visemeMetadata(2000000, 4, ""),
+			visemeMetadata(500000, 0, ""),
+			visemeMetadata(0, 0, animationChunk(2, 1)),
+			visemeMetadata(0, 0, animationChunk(0, 2)),
+		} {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(buildWSTextFrame("audio.metadata", "reqid", "application/json", body))))
+		}
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, buildWSBinaryFrame("audio", "reqid", "audio/x-wav", []byte("audio"))))
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(buildWSTextFrame("turn.end", "reqid", "application/json", "{}"))))
+	})
+
+	voice := ssml.NewVoice("en-US-JennyNeural")
+	voice.Child = []xml.Token{ssml.NewViseme(ssml.VisemeTypeFacialExpression), ssml.NewLang("en-US", "hello")}
+	result, err := tts.SynthesizeSsmlVisemesWithContext(context.Background(), voice, RIFF24khz16bitMonoPCM)
+	require.NoError(t, err)
+	assert.Equal(t, "audio", string(result.Audio))
+	assert.Equal(t, []Viseme{{Offset: 50 * time.Millisecond, ID: 0}, {Offset: 200 * time.Millisecond, ID: 4}}, result.Visemes)
+	require.Len(t, result.BlendShapes, 3)
+	for i, frame := range result.BlendShapes {
+		assert.Equal(t, time.Duration(i)*time.Second/60, frame.Offset)
+		assert.Equal(t, float64(i), frame.Weights[0])
+	}
+}
+
+func TestWriteAnimationTrack(t *testing.T) {
+	frames, err := decodeBlendShapes(animationChunk(0, 3))
+	require.NoError(t, err)
+	result := &VisemeSynthesis{
+		Visemes:     []Viseme{{Offset: 50 * time.Millisecond, ID: 0}, {Offset: 200 * time.Millisecond, ID: 4}},
+		BlendShapes: frames,
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, result.WriteAnimationTrack(&buf))
+	var track AnimationTrack
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &track))
+	assert.Equal(t, 0.2, track.Duration)
+	assert.Equal(t, []AnimationVisemeKey{{Time: 0.05, ID: 0}, {Time: 0.2, ID: 4}}, track.Visemes)
+	assert.Equal(t, BlendShapeFrameRate, track.FrameRate)
+	assert.Equal(t, "eyeBlinkLeft", track.BlendShapeNames[0])
+	require.Len(t, track.Frames, 3)
+	assert.InDelta(t, 2.0/60, track.Frames[2].Time, 1e-9)
+	assert.Len(t, track.Frames[2].Weights, BlendShapeCount)
+	assert.Equal(t, 2.0, track.Frames[2].Weights[0])
+
+	buf.Reset()
+	require.NoError(t, (&VisemeSynthesis{}).WriteAnimationTrack(&buf))
+	assert.JSONEq(t, `{"duration":0,"visemes":[]}`, buf.String())
+}
+
+func TestDecodeBlendShapesRejectsShortFrames(t *testing.T) {
+	_, err := decodeBlendShapes(`{"FrameIndex":0,"BlendShapes":[[0.1,0.2]]}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has 2 weights")
+}
+
+func TestWithViseme(t *testing.T) {
+	voice := ssml.NewVoice("en-US-JennyNeural")
+	voice.Child = "a &amp; b"
+	voice, err := withViseme(voice, ssml.VisemeTypeRedlipsFront)
+	require.NoError(t, err)
+	doc, err := xml.Marshal(voice)
+	require.NoError(t, err)
+	assert.Equal(t, `<voice name="en-US-JennyNeural"><mstts:viseme type="redlips_front"></mstts:viseme>a &amp; b</voice>`, string(doc))
+}
@@ -10,6 +10,10 @@ const (
 	VoiceNeutral                   // Neutral
 )
 
+// VoiceUnknown is reported by Usage for voices whose type could not be looked up in the voice list, e.g.
+// custom voices or any voice while the voice list cannot be downloaded.
+const VoiceUnknown VoiceType = -1
+
 /*
 
 {
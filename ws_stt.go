@@ -8,9 +8,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -155,13 +157,66 @@ func (az *AzureCSSTT) RecognizeWithContext(
 	languages []string,
 	opts ...Option,
 ) (<-chan RecognizeEvent, error) {
+	// the session stays registered with the client until its events channel is closed.
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release, err := az.client.limits.acquireRecognition(ctx)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	telemetry := az.client.opts.telemetry
+	ctx, op := telemetry.start(ctx, "recognize_stream", attrLanguage.String(strings.Join(languages, ",")))
+	start := time.Now()
+
 	conn, requestID, err := az.openRecognizeConnection(ctx, audioType, languages, opts...)
 	if err != nil {
+		op.end(err)
+		release()
+		end()
 		return nil, err
 	}
 
+	raw := make(chan RecognizeEvent, 8)
 	events := make(chan RecognizeEvent, 8)
-	go az.runRecognizeStream(ctx, conn, requestID, reader, events)
+	var audioSent atomic.Int64
+	go func() {
+		defer release()
+		az.runRecognizeStream(ctx, conn, requestID, reader, raw, &audioSent)
+	}()
+	go func() {
+		// relay the events to record the session's telemetry.
+		defer end()
+		defer close(events)
+		var sessionErr error
+		var audio time.Duration
+		first := true
+		for event := range raw {
+			switch event.Type {
+			case RecognizeEventPartial, RecognizeEventFinal:
+				if first {
+					first = false
+					telemetry.recordFirstHypothesis(ctx, time.Since(start))
+				}
+				if event.Type == RecognizeEventFinal && event.Result != nil {
+					audio = max(audio, ticksToDuration(event.Result.Offset+event.Result.Duration))
+				}
+			case RecognizeEventError:
+				sessionErr = event.Err
+			}
+			// a cancelled session drops its events rather than waiting for a caller which stopped reading.
+			sendRecognizeEvent(ctx, events, event)
+		}
+		telemetry.addRecognizedAudio(ctx, audio, attrLanguage.String(strings.Join(languages, ",")))
+		op.end(sessionErr)
+		az.client.recordUsage(ctx, Usage{
+			Operation:     "recognize_stream",
+			AudioDuration: time.Duration(audioSent.Load()),
+			Err:           sessionErr,
+		})
+	}()
 	return events, nil
 }
 
@@ -199,34 +254,29 @@ func (az *AzureCSSTT) openRecognizeConnection(
 		return nil, "", err
 	}
 
-	headers := http.Header{}
-	headers.Set("Authorization", "Bearer "+az.client.accessToken)
-	headers.Set("X-ConnectionId", connectionID)
-
-	dialer := websocket.Dialer{}
-	conn, resp, err := dialer.DialContext(ctx, endpoint, headers)
+	conn, err := az.client.dialWebsocket(ctx, endpoint, connectionID, recognizeStatusDescriptions)
 	if err != nil {
-		if resp != nil {
-			defer resp.Body.Close()
-			body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-			if len(body) > 0 {
-				return nil, "", fmt.Errorf("failed to connect to speech websocket: %w (status=%s body=%q)", err, resp.Status, string(body))
-			}
-			return nil, "", fmt.Errorf("failed to connect to speech websocket: %w (status=%s)", err, resp.Status)
-		}
-		return nil, "", fmt.Errorf("failed to connect to speech websocket: %w", err)
+		return nil, "", err
 	}
+	az.client.logger().DebugContext(ctx, "websocket session opened",
+		"url", redactRawURL(endpoint),
+		"connection_id", connectionID,
+		"request_id", requestID)
 
 	go func() {
 		<-ctx.Done()
 		_ = conn.Close()
 	}()
 
-	if err := writeWSTextFrame(conn, "speech.config", requestID, "application/json", buildWSSpeechConfig()); err != nil {
+	speechConfig := buildWSSpeechConfig(az.client.opts.systemName, az.client.opts.systemVersion)
+	az.client.logWSFrame(ctx, "sent", "speech.config", requestID, speechConfig)
+	if err := writeWSTextFrame(conn, "speech.config", requestID, "application/json", speechConfig); err != nil {
 		_ = conn.Close()
 		return nil, "", err
 	}
-	if err := writeWSTextFrame(conn, "speech.context", requestID, "application/json", buildWSSpeechContext(candidates)); err != nil {
+	speechContext := buildWSSpeechContext(candidates)
+	az.client.logWSFrame(ctx, "sent", "speech.context", requestID, speechContext)
+	if err := writeWSTextFrame(conn, "speech.context", requestID, "application/json", speechContext); err != nil {
 		_ = conn.Close()
 		return nil, "", err
 	}
@@ -234,20 +284,78 @@ func (az *AzureCSSTT) openRecognizeConnection(
 	return conn, requestID, nil
 }
 
+// dialWebsocket opens a speech websocket. A handshake rejected with 401 is retried once after the token
+// has been refreshed, and transient failures are retried according to the RetryPolicy. `descriptions`
+// explain the status of a rejected handshake.
+func (az *AzureCS) dialWebsocket(ctx context.Context, endpoint string, connectionID string, descriptions map[int]string) (*websocket.Conn, error) {
+	policy := az.opts.retryPolicy
+	reauthorized := false
+	for attempt := 1; ; attempt++ {
+		headers := http.Header{}
+		if err := az.authorize(ctx, headers); err != nil {
+			return nil, err
+		}
+		headers.Set("X-ConnectionId", connectionID)
+		if az.opts.userAgent != "" {
+			headers.Set("User-Agent", az.opts.userAgent)
+		}
+
+		dialer := websocket.Dialer{}
+		conn, resp, err := dialer.DialContext(ctx, endpoint, headers)
+		if err == nil {
+			return conn, nil
+		}
+
+		if resp != nil && resp.StatusCode == http.StatusUnauthorized && !reauthorized {
+			reauthorized = true
+			refreshed, refreshErr := az.reauthorize(ctx)
+			if refreshErr != nil {
+				resp.Body.Close()
+				return nil, refreshErr
+			}
+			if refreshed {
+				resp.Body.Close()
+				attempt--
+				continue
+			}
+		}
+
+		retryable := resp == nil || policy.isRetryableStatus(resp.StatusCode)
+		if attempt >= policy.MaxAttempts || !retryable || ctx.Err() != nil || !policy.wait(ctx, attempt, resp) {
+			return nil, wsHandshakeError(err, resp, descriptions)
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+	}
+}
+
+// wsHandshakeError describes a failed websocket handshake. When the service answered, the error wraps
+// an APIError carrying the status, service error and request ID.
+func wsHandshakeError(err error, resp *http.Response, descriptions map[int]string) error {
+	if resp == nil {
+		return fmt.Errorf("failed to connect to speech websocket: %w", err)
+	}
+	defer resp.Body.Close()
+	return fmt.Errorf("failed to connect to speech websocket: %w", newAPIError("websocket handshake", resp, descriptions))
+}
+
 func (az *AzureCSSTT) runRecognizeStream(
 	ctx context.Context,
 	conn *websocket.Conn,
 	requestID string,
 	reader io.Reader,
 	events chan<- RecognizeEvent,
+	audioSent *atomic.Int64,
 ) {
 	defer close(events)
 	defer conn.Close()
 
 	sendErrCh := make(chan error, 1)
 	go func() {
-		err := streamWSWaveAudio(ctx, conn, requestID, reader)
+		err := streamWSWaveAudio(ctx, conn, requestID, reader, audioSent)
 		if err == nil {
+			az.client.logWSFrame(ctx, "sent", "audio", requestID, "")
 			err = writeWSBinaryFrame(conn, "audio", requestID, "", nil)
 		}
 		if err != nil {
@@ -260,18 +368,18 @@ func (az *AzureCSSTT) runRecognizeStream(
 		messageType, payload, err := conn.ReadMessage()
 		if err != nil {
 			if ctx.Err() != nil {
-				events <- RecognizeEvent{Type: RecognizeEventError, Err: ctx.Err()}
+				sendRecognizeEvent(ctx, events, RecognizeEvent{Type: RecognizeEventError, Err: ctx.Err()})
 				return
 			}
 			select {
 			case sendErr := <-sendErrCh:
 				if sendErr != nil {
-					events <- RecognizeEvent{Type: RecognizeEventError, Err: sendErr}
+					sendRecognizeEvent(ctx, events, RecognizeEvent{Type: RecognizeEventError, Err: sendErr})
 					return
 				}
 			default:
 			}
-			events <- RecognizeEvent{Type: RecognizeEventError, Err: fmt.Errorf("failed to read speech websocket response: %w", err)}
+			sendRecognizeEvent(ctx, events, RecognizeEvent{Type: RecognizeEventError, Err: fmt.Errorf("failed to read speech websocket response: %w", err)})
 			return
 		}
 		if messageType != websocket.TextMessage {
@@ -280,17 +388,18 @@ func (az *AzureCSSTT) runRecognizeStream(
 
 		message, err := parseWSTextMessage(payload)
 		if err != nil {
-			events <- RecognizeEvent{Type: RecognizeEventError, Err: err}
+			sendRecognizeEvent(ctx, events, RecognizeEvent{Type: RecognizeEventError, Err: err})
 			return
 		}
+		az.client.logWSFrame(ctx, "received", message.headers["path"], message.headers["x-requestid"], message.body)
 
 		event, done, err := parseWSRecognizeEvent(message)
 		if err != nil {
-			events <- RecognizeEvent{Type: RecognizeEventError, Err: err}
+			sendRecognizeEvent(ctx, events, RecognizeEvent{Type: RecognizeEventError, Err: err})
 			return
 		}
 		if event != nil {
-			events <- *event
+			sendRecognizeEvent(ctx, events, *event)
 		}
 		if done {
 			return
@@ -299,7 +408,7 @@ func (az *AzureCSSTT) runRecognizeStream(
 		select {
 		case sendErr := <-sendErrCh:
 			if sendErr != nil {
-				events <- RecognizeEvent{Type: RecognizeEventError, Err: sendErr}
+				sendRecognizeEvent(ctx, events, RecognizeEvent{Type: RecognizeEventError, Err: sendErr})
 				return
 			}
 		default:
@@ -307,6 +416,19 @@ func (az *AzureCSSTT) runRecognizeStream(
 	}
 }
 
+// sendRecognizeEvent delivers `event`, or drops it once `ctx` is done and the caller is not reading.
+func sendRecognizeEvent(ctx context.Context, events chan<- RecognizeEvent, event RecognizeEvent) {
+	select {
+	case events <- event:
+		return
+	default:
+	}
+	select {
+	case events <- event:
+	case <-ctx.Done():
+	}
+}
+
 func parseWSRecognizeEvent(message *wsTextMessage) (*RecognizeEvent, bool, error) {
 	switch strings.ToLower(message.headers["path"]) {
 	case "speech.hypothesis":
@@ -356,12 +478,12 @@ func (az *AzureCSSTT) buildAutoDetectWSEndpoint(params options) (string, error)
 	return baseURL.String(), nil
 }
 
-func buildWSSpeechConfig() string {
+func buildWSSpeechConfig(systemName, systemVersion string) string {
 	payload := wsSpeechConfig{
 		Context: wsSpeechConfigContext{
 			System: wsSpeechConfigSystem{
-				Name:    "azure-cs-sdk",
-				Version: "0.0.0",
+				Name:    systemName,
+				Version: systemVersion,
 				Build:   "Go",
 				Lang:    "Go",
 			},
@@ -402,7 +524,9 @@ func buildWSSpeechContext(languages []string) string {
 	return string(data)
 }
 
-func streamWSWaveAudio(ctx context.Context, conn *websocket.Conn, requestID string, reader io.Reader) error {
+// streamWSWaveAudio sends the wav audio of `reader` in real time, adding the length of the audio sent so far
+// to `audioSent`.
+func streamWSWaveAudio(ctx context.Context, conn *websocket.Conn, requestID string, reader io.Reader, audioSent *atomic.Int64) error {
 	header := make([]byte, wavHeaderSize)
 	if _, err := io.ReadFull(reader, header); err != nil {
 		return fmt.Errorf("failed to read wav header: %w", err)
@@ -427,6 +551,7 @@ func streamWSWaveAudio(ctx context.Context, conn *websocket.Conn, requestID stri
 				return err
 			}
 			bytesSent += int64(n)
+			audioSent.Add(int64(time.Duration(n) * time.Second / time.Duration(byteRate)))
 		}
 		if err == io.EOF {
 			return nil
@@ -487,6 +612,36 @@ func parseWSTextMessage(payload []byte) (*wsTextMessage, error) {
 	return &wsTextMessage{headers: headers, body: body}, nil
 }
 
+// parseWSBinaryMessage splits a binary frame into its headers, whose length is given by the first two
+// bytes, and its body.
+func parseWSBinaryMessage(payload []byte) (*wsTextMessage, []byte, error) {
+	if len(payload) < 2 {
+		return nil, nil, fmt.Errorf("websocket binary frame of %d bytes has no header length", len(payload))
+	}
+	headerLen := int(binary.BigEndian.Uint16(payload[:2]))
+	if 2+headerLen > len(payload) {
+		return nil, nil, fmt.Errorf("websocket binary frame of %d bytes is shorter than its %d bytes of headers", len(payload), headerLen)
+	}
+	message, err := parseWSTextMessage(payload[2 : 2+headerLen])
+	if err != nil {
+		return nil, nil, err
+	}
+	return message, payload[2+headerLen:], nil
+}
+
+// logWSFrame logs a websocket text frame, or the end of the audio stream, at debug level. Audio chunks
+// are not logged.
+func (az *AzureCS) logWSFrame(ctx context.Context, direction, path, requestID, body string) {
+	logger := az.logger()
+	if !logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	logger.DebugContext(ctx, "websocket frame "+direction,
+		"path", path,
+		"request_id", requestID,
+		"body", body)
+}
+
 func writeWSTextFrame(conn *websocket.Conn, path, requestID, contentType, body string) error {
 	frame := buildWSTextFrame(path, requestID, contentType, body)
 	if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
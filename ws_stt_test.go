@@ -78,7 +78,7 @@ func TestRecognizeStream(t *testing.T) {
 	stt := &AzureCSSTT{
 		speechToTextWSAPI: strings.Replace(server.URL, "http://", "ws://", 1),
 		client: &AzureCS{
-			accessToken: "token",
+			tokenProvider: NewStaticTokenProvider("token"),
 		},
 	}
 
@@ -135,7 +135,7 @@ func TestRecognizeFinalEvent(t *testing.T) {
 
 	stt := &AzureCSSTT{
 		speechToTextWSAPI: strings.Replace(server.URL, "http://", "ws://", 1),
-		client:            &AzureCS{accessToken: "token"},
+		client:            &AzureCS{tokenProvider: NewStaticTokenProvider("token")},
 	}
 
 	wav := append(make([]byte, wavHeaderSize), []byte("pcmdata")...)
@@ -161,7 +161,7 @@ func TestRecognizeFinalEvent(t *testing.T) {
 }
 
 func TestRecognizeRejectsUnsupportedAudioType(t *testing.T) {
-	stt := &AzureCSSTT{client: &AzureCS{accessToken: "token"}}
+	stt := &AzureCSSTT{client: &AzureCS{tokenProvider: NewStaticTokenProvider("token")}}
 	_, err := stt.Recognize(strings.NewReader("audio"), OGG16khz16bitMonoOpus, []string{"en-US"})
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), fmt.Sprintf("%s", RIFF16khz16bitMonoPCM))
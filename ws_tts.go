@@ -0,0 +1,356 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type SynthesisEventType string
+
+const (
+	SynthesisEventAudio            SynthesisEventType = "audio"
+	SynthesisEventWordBoundary     SynthesisEventType = "word_boundary"
+	SynthesisEventSentenceBoundary SynthesisEventType = "sentence_boundary"
+	SynthesisEventViseme           SynthesisEventType = "viseme"
+	SynthesisEventBookmark         SynthesisEventType = "bookmark"
+	SynthesisEventError            SynthesisEventType = "error"
+)
+
+// SynthesisEvent is an event of a websocket synthesis session. The field matching Type is set.
+type SynthesisEvent struct {
+	Type SynthesisEventType
+	// Audio is the next chunk of audio, in the requested output format.
+	Audio            []byte
+	WordBoundary     *WordBoundary
+	SentenceBoundary *SentenceBoundary
+	Viseme           *Viseme
+	Bookmark         *Bookmark
+	Err              error
+}
+
+// WordBoundary reports that a word is spoken. Offsets are from the start of the audio.
+type WordBoundary struct {
+	Offset   time.Duration
+	Duration time.Duration
+	// Text is the word, and Length its length in characters.
+	Text   string
+	Length int
+}
+
+// SentenceBoundary reports that a sentence is spoken. Offsets are from the start of the audio.
+type SentenceBoundary struct {
+	Offset   time.Duration
+	Duration time.Duration
+	// Text is the sentence, and Length its length in characters.
+	Text   string
+	Length int
+}
+
+// Viseme reports the mouth position at Offset from the start of the audio.
+// See https://learn.microsoft.com/en-us/azure/ai-services/speech-service/how-to-speech-synthesis-viseme
+type Viseme struct {
+	Offset time.Duration
+	// ID is the viseme ID, from 0 to 21.
+	ID int
+	// Animation is the JSON animation chunk sent when the document requests an animation viseme type,
+	// such as FacialExpression, and is empty otherwise.
+	Animation string
+}
+
+// Bookmark reports that the audio reached a bookmark element of the document.
+type Bookmark struct {
+	Offset time.Duration
+	// Name is the mark attribute of the bookmark element.
+	Name string
+}
+
+type wsSynthesisContext struct {
+	Synthesis wsSynthesisContextSynthesis `json:"synthesis"`
+}
+
+type wsSynthesisContextSynthesis struct {
+	Audio    wsSynthesisAudio    `json:"audio"`
+	Language wsSynthesisLanguage `json:"language"`
+}
+
+type wsSynthesisAudio struct {
+	MetadataOptions wsSynthesisMetadataOptions `json:"metadataOptions"`
+	OutputFormat    string                     `json:"outputFormat"`
+}
+
+type wsSynthesisMetadataOptions struct {
+	BookmarkEnabled            bool `json:"bookmarkEnabled"`
+	PunctuationBoundaryEnabled bool `json:"punctuationBoundaryEnabled"`
+	SentenceBoundaryEnabled    bool `json:"sentenceBoundaryEnabled"`
+	WordBoundaryEnabled        bool `json:"wordBoundaryEnabled"`
+	VisemeEnabled              bool `json:"visemeEnabled"`
+	SessionEndEnabled          bool `json:"sessionEndEnabled"`
+}
+
+type wsSynthesisLanguage struct {
+	AutoDetection bool `json:"autoDetection"`
+}
+
+// wsAudioMetadata is the body of an audio.metadata frame.
+type wsAudioMetadata struct {
+	Metadata []struct {
+		Type string
+		Data struct {
+			Offset   uint64
+			Duration uint64
+			Text     struct {
+				Text   string
+				Length int
+			} `json:"text"`
+			VisemeID       int `json:"VisemeId"`
+			AnimationChunk string
+			Bookmark       string
+		}
+	}
+}
+
+// SynthesizeEventsWithContext synthesizes `speechText` over the websocket API. The returned channel
+// delivers the audio as it is rendered, along with the word and sentence boundaries, visemes and
+// bookmarks, and is closed once the synthesis is complete. A failure is delivered as an event of type
+// SynthesisEventError, after which the channel is closed.
+func (az *AzureCSTTS) SynthesizeEventsWithContext(ctx context.Context, speechText string, voiceName string, audioOutput AudioType) (<-chan SynthesisEvent, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	voice, err := az.textVoice(ctx, speechText, voiceName)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	doc, err := speakDocument(voice)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	return az.synthesizeEvents(ctx, end, doc, audioOutput)
+}
+
+// SynthesizeSsmlEventsWithContext is SynthesizeEventsWithContext for an SSML document.
+func (az *AzureCSTTS) SynthesizeSsmlEventsWithContext(ctx context.Context, elems xml.Token, audioOutput AudioType) (<-chan SynthesisEvent, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := speakDocument(elems)
+	if err != nil {
+		end()
+		return nil, err
+	}
+	return az.synthesizeEvents(ctx, end, doc, audioOutput)
+}
+
+// SynthesizeRawSsmlEventsWithContext is SynthesizeEventsWithContext for a raw SSML document.
+func (az *AzureCSTTS) SynthesizeRawSsmlEventsWithContext(ctx context.Context, ssml string, audioOutput AudioType) (<-chan SynthesisEvent, error) {
+	ctx, end, err := az.client.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return az.synthesizeEvents(ctx, end, ssml, audioOutput)
+}
+
+// synthesizeEvents opens the websocket session synthesizing `ssml`. `end` is called once the events
+// channel is closed, or straight away when the session cannot be opened.
+func (az *AzureCSTTS) synthesizeEvents(ctx context.Context, end func(), ssml string, audioOutput AudioType) (<-chan SynthesisEvent, error) {
+	audioOutput = az.client.outputFormat(audioOutput)
+	ctx, record := az.startSynthesis(ctx, ssml, audioOutput)
+	conn, requestID, err := az.openSynthesisConnection(ctx, ssml, audioOutput)
+	if err != nil {
+		record.finish(az.client, 0, err)
+		end()
+		return nil, err
+	}
+
+	events := make(chan SynthesisEvent, 8)
+	go func() {
+		defer end()
+		defer close(events)
+		n, err := az.runSynthesisSession(ctx, conn, requestID, events)
+		if err != nil {
+			sendSynthesisEvent(ctx, events, SynthesisEvent{Type: SynthesisEventError, Err: err})
+		}
+		record.finish(az.client, n, err)
+	}()
+	return events, nil
+}
+
+// openSynthesisConnection dials the websocket and sends the speech.config, synthesis.context and ssml
+// frames. The connection is closed once `ctx` is done.
+func (az *AzureCSTTS) openSynthesisConnection(ctx context.Context, ssml string, audioOutput AudioType) (*websocket.Conn, string, error) {
+	requestID, err := newWSRequestID()
+	if err != nil {
+		return nil, "", err
+	}
+	connectionID, err := newWSRequestID()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := az.client.limits.waitRequest(ctx); err != nil {
+		return nil, "", err
+	}
+
+	conn, err := az.client.dialWebsocket(ctx, az.textToSpeechWSURL, connectionID, synthesizeStatusDescriptions)
+	if err != nil {
+		return nil, "", err
+	}
+	az.client.logger().DebugContext(ctx, "websocket session opened",
+		"url", redactRawURL(az.textToSpeechWSURL),
+		"connection_id", connectionID,
+		"request_id", requestID)
+	context.AfterFunc(ctx, func() {
+		_ = conn.Close()
+	})
+
+	frames := []struct {
+		path        string
+		contentType string
+		body        string
+	}{
+		{"speech.config", "application/json", buildWSSpeechConfig(az.client.opts.systemName, az.client.opts.systemVersion)},
+		{"synthesis.context", "application/json", buildWSSynthesisContext(audioOutput)},
+		{"ssml", "application/ssml+xml", ssml},
+	}
+	for _, frame := range frames {
+		az.client.logWSFrame(ctx, "sent", frame.path, requestID, frame.body)
+		if err := writeWSTextFrame(conn, frame.path, requestID, frame.contentType, frame.body); err != nil {
+			_ = conn.Close()
+			return nil, "", err
+		}
+	}
+	return conn, requestID, nil
+}
+
+// runSynthesisSession relays the frames of the session to `events` until turn.end, and returns the
+// number of bytes of audio received.
+func (az *AzureCSTTS) runSynthesisSession(ctx context.Context, conn *websocket.Conn, requestID string, events chan<- SynthesisEvent) (int, error) {
+	defer conn.Close()
+
+	n := 0
+	for {
+		messageType, payload, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return n, ctx.Err()
+			}
+			return n, fmt.Errorf("failed to read synthesis websocket response: %w", err)
+		}
+
+		if messageType == websocket.BinaryMessage {
+			message, audio, err := parseWSBinaryMessage(payload)
+			if err != nil {
+				return n, err
+			}
+			if !strings.EqualFold(message.headers["path"], "audio") || len(audio) == 0 {
+				continue
+			}
+			n += len(audio)
+			sendSynthesisEvent(ctx, events, SynthesisEvent{Type: SynthesisEventAudio, Audio: audio})
+			continue
+		}
+
+		message, err := parseWSTextMessage(payload)
+		if err != nil {
+			return n, err
+		}
+		az.client.logWSFrame(ctx, "received", message.headers["path"], message.headers["x-requestid"], message.body)
+
+		switch strings.ToLower(message.headers["path"]) {
+		case "audio.metadata":
+			metadata, err := parseWSAudioMetadata(message.body)
+			if err != nil {
+				return n, err
+			}
+			for _, event := range metadata {
+				sendSynthesisEvent(ctx, events, event)
+			}
+		case "turn.end":
+			return n, nil
+		}
+	}
+}
+
+// parseWSAudioMetadata decodes the events of an audio.metadata frame. Unknown kinds of metadata, such
+// as SessionEnd, are skipped.
+func parseWSAudioMetadata(body string) ([]SynthesisEvent, error) {
+	var metadata wsAudioMetadata
+	if err := json.Unmarshal([]byte(body), &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode synthesis metadata: %w", err)
+	}
+
+	var events []SynthesisEvent
+	for _, item := range metadata.Metadata {
+		data := item.Data
+		offset := ticksToDuration(data.Offset)
+		switch item.Type {
+		case "WordBoundary":
+			events = append(events, SynthesisEvent{Type: SynthesisEventWordBoundary, WordBoundary: &WordBoundary{
+				Offset:   offset,
+				Duration: ticksToDuration(data.Duration),
+				Text:     data.Text.Text,
+				Length:   data.Text.Length,
+			}})
+		case "SentenceBoundary":
+			events = append(events, SynthesisEvent{Type: SynthesisEventSentenceBoundary, SentenceBoundary: &SentenceBoundary{
+				Offset:   offset,
+				Duration: ticksToDuration(data.Duration),
+				Text:     data.Text.Text,
+				Length:   data.Text.Length,
+			}})
+		case "Viseme":
+			events = append(events, SynthesisEvent{Type: SynthesisEventViseme, Viseme: &Viseme{
+				Offset:    offset,
+				ID:        data.VisemeID,
+				Animation: data.AnimationChunk,
+			}})
+		case "Bookmark":
+			events = append(events, SynthesisEvent{Type: SynthesisEventBookmark, Bookmark: &Bookmark{
+				Offset: offset,
+				Name:   data.Bookmark,
+			}})
+		}
+	}
+	return events, nil
+}
+
+// sendSynthesisEvent delivers `event`, or drops it once `ctx` is done and the caller is not reading.
+func sendSynthesisEvent(ctx context.Context, events chan<- SynthesisEvent, event SynthesisEvent) {
+	select {
+	case events <- event:
+		return
+	default:
+	}
+	select {
+	case events <- event:
+	case <-ctx.Done():
+	}
+}
+
+func buildWSSynthesisContext(audioOutput AudioType) string {
+	payload := wsSynthesisContext{
+		Synthesis: wsSynthesisContextSynthesis{
+			Audio: wsSynthesisAudio{
+				MetadataOptions: wsSynthesisMetadataOptions{
+					BookmarkEnabled:         true,
+					SentenceBoundaryEnabled: true,
+					WordBoundaryEnabled:     true,
+					VisemeEnabled:           true,
+					SessionEndEnabled:       true,
+				},
+				OutputFormat: audioOutput.String(),
+			},
+		},
+	}
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
@@ -0,0 +1,117 @@
+package azure_cs_sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSynthesisClient(t *testing.T, handler func(conn *websocket.Conn)) *AzureCSTTS {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+		handler(conn)
+	}))
+	t.Cleanup(server.Close)
+
+	az, _, err := NewWithTokenProvider(http.DefaultClient, NewStaticTokenProvider("token"), RegionWestUS2,
+		WithLazyInit(), WithTextToSpeechWSAPI(strings.Replace(server.URL, "http://", "ws://", 1)))
+	require.NoError(t, err)
+	tts, err := az.NewTTS()
+	require.NoError(t, err)
+	return tts
+}
+
+func TestSynthesizeEvents(t *testing.T) {
+	tts := newTestSynthesisClient(t, func(conn *websocket.Conn) {
+		for _, path := range []string{"speech.config", "synthesis.context", "ssml"} {
+			msgType, frame, err := conn.ReadMessage()
+			require.NoError(t, err)
+			assert.Equal(t, websocket.TextMessage, msgType)
+			message, err := parseWSTextMessage(frame)
+			require.NoError(t, err)
+			assert.Equal(t, path, message.headers["path"])
+			switch path {
+			case "synthesis.context":
+				assert.Contains(t, message.body, `"outputFormat":"riff-24khz-16bit-mono-pcm"`)
+				assert.Contains(t, message.body, `"wordBoundaryEnabled":true`)
+			case "ssml":
+				assert.Equal(t, "application/ssml+xml", message.headers["content-type"])
+				assert.Equal(t, `<speak>hello <bookmark mark="m1"/>world</speak>`, message.body)
+			}
+		}
+
+		writeText := func(path, body string) {
+			require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(buildWSTextFrame(path, "reqid", "application/json", body))))
+		}
+		writeText("turn.start", `{}`)
+		writeText("audio.metadata", `{"Metadata":[
+			{"Type":"SentenceBoundary","Data":{"Offset":500000,"Duration":9000000,"text":{"Text":"hello world","Length":11,"BoundaryType":"SentenceBoundary"}}},
+			{"Type":"WordBoundary","Data":{"Offset":500000,"Duration":3250000,"text":{"Text":"hello","Length":5,"BoundaryType":"WordBoundary"}}},
+			{"Type":"Viseme","Data":{"Offset":1000000,"VisemeId":12}}
+		]}`)
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, buildWSBinaryFrame("audio", "reqid", "audio/x-wav", []byte("chunk1"))))
+		writeText("audio.metadata", `{"Metadata":[{"Type":"Bookmark","Data":{"Offset":4000000,"Bookmark":"m1"}},{"Type":"SessionEnd","Data":{"Offset":10000000}}]}`)
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, buildWSBinaryFrame("audio", "reqid", "audio/x-wav", []byte("chunk2"))))
+		require.NoError(t, conn.WriteMessage(websocket.BinaryMessage, buildWSBinaryFrame("audio", "reqid", "", nil)))
+		writeText("turn.end", `{}`)
+	})
+
+	events, err := tts.SynthesizeRawSsmlEventsWithContext(context.Background(), `<speak>hello <bookmark mark="m1"/>world</speak>`, RIFF24khz16bitMonoPCM)
+	require.NoError(t, err)
+
+	var got []SynthesisEvent
+	for event := range events {
+		got = append(got, event)
+	}
+	assert.Equal(t, []SynthesisEvent{
+		{Type: SynthesisEventSentenceBoundary, SentenceBoundary: &SentenceBoundary{Offset: 50 * time.Millisecond, Duration: 900 * time.Millisecond, Text: "hello world", Length: 11}},
+		{Type: SynthesisEventWordBoundary, WordBoundary: &WordBoundary{Offset: 50 * time.Millisecond, Duration: 325 * time.Millisecond, Text: "hello", Length: 5}},
+		{Type: SynthesisEventViseme, Viseme: &Viseme{Offset: 100 * time.Millisecond, ID: 12}},
+		{Type: SynthesisEventAudio, Audio: []byte("chunk1")},
+		{Type: SynthesisEventBookmark, Bookmark: &Bookmark{Offset: 400 * time.Millisecond, Name: "m1"}},
+		{Type: SynthesisEventAudio, Audio: []byte("chunk2")},
+	}, got)
+	assert.Zero(t, tts.client.lifecycle.outstanding())
+}
+
+func TestSynthesizeEventsClosedByService(t *testing.T) {
+	tts := newTestSynthesisClient(t, func(conn *websocket.Conn) {
+		for i := 0; i < 3; i++ {
+			_, _, err := conn.ReadMessage()
+			require.NoError(t, err)
+		}
+		message := websocket.FormatCloseMessage(websocket.CloseInvalidFramePayloadData, "invalid SSML")
+		require.NoError(t, conn.WriteMessage(websocket.CloseMessage, message))
+	})
+
+	events, err := tts.SynthesizeRawSsmlEventsWithContext(context.Background(), "<speak", RIFF24khz16bitMonoPCM)
+	require.NoError(t, err)
+
+	event, ok := <-events
+	require.True(t, ok)
+	assert.Equal(t, SynthesisEventError, event.Type)
+	var closeErr *websocket.CloseError
+	require.ErrorAs(t, event.Err, &closeErr)
+	assert.Equal(t, "invalid SSML", closeErr.Text)
+
+	_, ok = <-events
+	assert.False(t, ok)
+}
+
+func TestParseWSBinaryMessageRejectsShortFrames(t *testing.T) {
+	_, _, err := parseWSBinaryMessage([]byte{0})
+	assert.Error(t, err)
+	_, _, err = parseWSBinaryMessage([]byte{0, 10, 'P'})
+	assert.Error(t, err)
+}